@@ -19,18 +19,14 @@ type Cached struct {
 
 var (
 	logger = util.GetLogger("cache")
-	Cache  = Cached{
+	// the zero expire makes the first Get load the proxies
+	Cache = Cached{
 		proxies: map[string][]model.HttpProxy{},
 	}
 	engine       = db.GetDb()
 	cacheTimeout = time.Duration(util.ServerConf.ProxyCacheTimeOut)
 )
 
-func init() {
-	Cache.proxies = getProxyMap()
-	Cache.expire = time.Now().Add(cacheTimeout * time.Second)
-}
-
 func getProxyMap() map[string][]model.HttpProxy {
 	m := map[string][]model.HttpProxy{
 		"forward":  nil,
@@ -55,12 +51,32 @@ func getProxyMap() map[string][]model.HttpProxy {
 			logger.WithField("type", k).WithError(err).Error("get proxy error")
 			continue
 		}
-		m[k] = p
+		m[k] = append(p, getStatic(k == "tunnel", util.ServerConf.ScoreAtLeast)...)
 	}
 
 	return m
 }
 
+// getStatic returns the static proxies the score filter left out, their health decides if they are used
+func getStatic(tunnel bool, scoreAtLeast int) (p []model.HttpProxy) {
+	options := map[string]string{
+		"static": "true",
+	}
+	if tunnel {
+		options["tunnel"] = "true"
+	}
+	all, err := engine.Get(options)
+	if err != nil {
+		return
+	}
+	for _, s := range all {
+		if s.Score < scoreAtLeast {
+			p = append(p, s)
+		}
+	}
+	return
+}
+
 func (c *Cached) Get() *map[string][]model.HttpProxy {
 	c.m.RLock()
 	defer c.m.RUnlock()
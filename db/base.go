@@ -2,6 +2,7 @@ package db
 
 import (
     "fmt"
+    "sync"
 
     "github.com/go-redis/redis/v7"
 
@@ -13,6 +14,7 @@ var (
     config = util.ServerConf
     logger = util.GetLogger("db")
     db     Store
+    lock   sync.Mutex
 )
 
 type Store interface {
@@ -23,6 +25,8 @@ type Store interface {
     Exists(model.HttpProxy) bool
     Add(model.HttpProxy) bool
     UpdateSchema(model.HttpProxy) error
+    // Update loads the stored proxy, lets fn modify it and saves it back
+    Update(model.HttpProxy, func(*model.HttpProxy)) error
     Remove(model.HttpProxy) error
     RemoveAll([]model.HttpProxy) error
     Random() (model.HttpProxy, error)
@@ -31,35 +35,126 @@ type Store interface {
     AddScore(key model.HttpProxy, score int) error
 }
 
+// GetDb returns the store, it connects on first use so the packages holding it
+// at package level do not need redis or bolt unless they touch it, e.g. a JudgeOnly node
 func GetDb() Store {
+    lock.Lock()
+    defer lock.Unlock()
     if db == nil {
-        switch config.DataStore {
-        case "redis":
-            db = &redisDB{
-                client: redis.NewClient(&redis.Options{
-                    Addr:     fmt.Sprintf("%s:%d", config.RedisHost, config.RedisPort),
-                    Password: config.RedisAuth, // no password set
-                    DB:       config.RedisDb,   // use default DB
-                }),
-                PrefixKey: config.PrefixKey,
-                KeyExpire: config.Expire,
-            }
-        case "bolt":
-            db = &boltDB{
-                BucketName: []byte(config.PrefixKey),
-                KeyExpire: config.Expire,
-                DataDir: config.DataDir,
-            }
-        default:
-            panic(fmt.Sprintf("invalid DataStore: %s", config.DataStore))
+        db = &lazyStore{}
+    }
+
+    return db
+}
+
+func newStore() Store {
+    var s Store
+    switch config.DataStore {
+    case "redis":
+        if config.CredentialKey == "" {
+            logger.Warn("CredentialKey is not set, proxies with credentials will not be saved to redis")
         }
-        if err := db.Init(); err != nil {
-            panic("db init error")
+        s = &redisDB{
+            client: redis.NewClient(&redis.Options{
+                Addr:     fmt.Sprintf("%s:%d", config.RedisHost, config.RedisPort),
+                Password: config.RedisAuth, // no password set
+                DB:       config.RedisDb,   // use default DB
+            }),
+            PrefixKey: config.PrefixKey,
+            KeyExpire: config.Expire,
         }
-        if !db.Test() {
-            panic("db test error")
+    case "bolt":
+        s = &boltDB{
+            BucketName: []byte(config.PrefixKey),
+            KeyExpire:  config.Expire,
+            DataDir:    config.DataDir,
         }
+    default:
+        panic(fmt.Sprintf("invalid DataStore: %s", config.DataStore))
+    }
+    if err := s.Init(); err != nil {
+        panic("db init error")
     }
+    if !s.Test() {
+        panic("db test error")
+    }
+    return s
+}
 
-    return db
+// lazyStore opens the configured store the first time it is used
+type lazyStore struct {
+    lock  sync.Mutex
+    store Store
+}
+
+func (l *lazyStore) get() Store {
+    l.lock.Lock()
+    defer l.lock.Unlock()
+    if l.store == nil {
+        l.store = newStore()
+    }
+    return l.store
+}
+
+func (l *lazyStore) Init() error {
+    l.get()
+    return nil
+}
+
+// Close does not open a store which was never used
+func (l *lazyStore) Close() error {
+    l.lock.Lock()
+    defer l.lock.Unlock()
+    if l.store == nil {
+        return nil
+    }
+    return l.store.Close()
+}
+
+func (l *lazyStore) GetAll() []model.HttpProxy {
+    return l.get().GetAll()
+}
+
+func (l *lazyStore) Get(options map[string]string) ([]model.HttpProxy, error) {
+    return l.get().Get(options)
+}
+
+func (l *lazyStore) Exists(p model.HttpProxy) bool {
+    return l.get().Exists(p)
+}
+
+func (l *lazyStore) Add(p model.HttpProxy) bool {
+    return l.get().Add(p)
+}
+
+func (l *lazyStore) UpdateSchema(p model.HttpProxy) error {
+    return l.get().UpdateSchema(p)
+}
+
+func (l *lazyStore) Update(p model.HttpProxy, fn func(*model.HttpProxy)) error {
+    return l.get().Update(p, fn)
+}
+
+func (l *lazyStore) Remove(p model.HttpProxy) error {
+    return l.get().Remove(p)
+}
+
+func (l *lazyStore) RemoveAll(p []model.HttpProxy) error {
+    return l.get().RemoveAll(p)
+}
+
+func (l *lazyStore) Random() (model.HttpProxy, error) {
+    return l.get().Random()
+}
+
+func (l *lazyStore) Len() int {
+    return l.get().Len()
+}
+
+func (l *lazyStore) Test() bool {
+    return l.get().Test()
+}
+
+func (l *lazyStore) AddScore(p model.HttpProxy, score int) error {
+    return l.get().AddScore(p, score)
 }
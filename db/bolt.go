@@ -68,6 +68,7 @@ func (self *boltDB) Close() error {
     return err
 }
 
+// GetByKey returns keyNotExists for an expired proxy as well, and deletes it
 func (self *boltDB) GetByKey(key string) (model.HttpProxy, error) {
     var proxy model.HttpProxy
 
@@ -84,20 +85,59 @@ func (self *boltDB) GetByKey(key string) (model.HttpProxy, error) {
         }
         return nil
     })
+    if err == keyExpired {
+        self.removeExpired([][]byte{[]byte(key)})
+        return proxy, keyNotExists
+    }
     return proxy, err
 }
 
+// removeExpired deletes the keys which are still expired, a proxy saved again in the meantime stays
+func (self *boltDB) removeExpired(keys [][]byte) {
+    if len(keys) == 0 {
+        return
+    }
+    err := self.db.Update(func(tx *bolt.Tx) error {
+        bucket := tx.Bucket(self.BucketName)
+        for _, key := range keys {
+            value := bucket.Get(key)
+            if value == nil {
+                continue
+            }
+            var p model.HttpProxy
+            if err := json.Unmarshal(value, &p); err != nil || !expired(&p) {
+                continue
+            }
+            if err := bucket.Delete(key); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        logger.WithError(err).Error("remove expired error")
+    }
+}
+
 func (self *boltDB) marshal(proxy model.HttpProxy, deadline *time.Time) ([]byte, error) {
-    dict := proxy.GetProxyMap()
-    if deadline != nil {
+    if proxy.ExpiresAt > 0 {
+        expiresAt := time.Unix(proxy.ExpiresAt, 0)
+        deadline = &expiresAt
+    }
+    // static proxies never expire
+    if deadline != nil && !proxy.Static {
         deadlineText, err := deadline.MarshalText()
         if err != nil {
             return nil, err
         }
-        dict["Deadline"] = deadlineText
+        proxy.Deadline = string(deadlineText)
+    }
+    proxy, err := proxy.Sealed()
+    if err != nil {
+        return nil, err
     }
 
-    data, err := json.Marshal(dict)
+    data, err := json.Marshal(proxy)
     if err != nil {
         return nil, err
     }
@@ -116,6 +156,9 @@ func (self *boltDB) unmarshal(data []byte) (model.HttpProxy, error) {
     if expired(&p) {
         return model.HttpProxy{}, keyExpired
     }
+    if err = p.Unseal(); err != nil {
+        return model.HttpProxy{}, err
+    }
 
     return p, nil
 }
@@ -147,9 +190,10 @@ func (self *boltDB) Add(proxy model.HttpProxy) bool {
     key := proxy.GetKey()
     _, err := self.GetByKey(key)
     if err == nil {
-        err := self.AddScore(proxy, 10)
-        if err != nil {
-            logger.WithError(err).Error("add score error")
+        // a proxy found again keeps its score, its deadline, expiry and credentials are renewed
+        if err := self.Update(proxy, func(stored *model.HttpProxy) {
+            stored.Renew(proxy)
+        }); err != nil {
             return false
         }
     } else if err == keyNotExists {
@@ -172,16 +216,24 @@ func (self *boltDB) Add(proxy model.HttpProxy) bool {
     return true
 }
 
+// GetAll skips the expired proxies and deletes them, a proxy which can not be read is skipped and logged
 func (self *boltDB) GetAll() []model.HttpProxy {
     var proxies []model.HttpProxy
+    var expiredKeys [][]byte
 
     err := self.db.View(func(tx *bolt.Tx) error {
         bucket := tx.Bucket(self.BucketName)
         cursor := bucket.Cursor()
         for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
             proxy, err := self.unmarshal(v)
+            if err == keyExpired {
+                // k is only valid during the transaction
+                expiredKeys = append(expiredKeys, append([]byte(nil), k...))
+                continue
+            }
             if err != nil {
-                return err
+                logger.WithError(err).WithField("key", string(k)).Warn("skip invalid proxy")
+                continue
             }
             proxies = append(proxies, proxy)
         }
@@ -191,6 +243,7 @@ func (self *boltDB) GetAll() []model.HttpProxy {
         logger.WithError(err).Error("get all error")
         return nil
     }
+    self.removeExpired(expiredKeys)
     return proxies
 }
 
@@ -273,30 +326,11 @@ func (self *boltDB) RemoveAll(proxies []model.HttpProxy) error {
 }
 
 func (self *boltDB) Random() (model.HttpProxy, error) {
-    var proxy model.HttpProxy
-    keyCount := self.Len()
-    if keyCount <= 0 {
+    all := self.GetAll()
+    if len(all) == 0 {
         return model.HttpProxy{}, noProxy
     }
-    keyIndex := rand.Intn(keyCount)
-    err := self.db.View(func(tx *bolt.Tx) error {
-        bucket := tx.Bucket(self.BucketName)
-        cursor := bucket.Cursor()
-        i := 0
-        var err error
-        for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
-            if i == keyIndex {
-                proxy, err = self.unmarshal(v)
-                return err
-            }
-            i += 1
-        }
-        return noProxy
-    })
-    if err != nil {
-        logger.WithError(err).Error("get random error")
-    }
-    return proxy, err
+    return all[rand.Intn(len(all))], nil
 }
 
 func (self *boltDB) Len() int {
@@ -338,6 +372,34 @@ func (self *boltDB) UpdateSchema(proxy model.HttpProxy) error {
     return err
 }
 
+func (self *boltDB) Update(proxy model.HttpProxy, fn func(*model.HttpProxy)) error {
+    key := proxy.GetKey()
+    err := self.db.Update(func(tx *bolt.Tx) error {
+        bucket := tx.Bucket(self.BucketName)
+        stored, err := self.unmarshal(bucket.Get([]byte(key)))
+        if err == keyExpired {
+            return err
+        }
+        if err != nil {
+            return keyNotExists
+        }
+        fn(&stored)
+        value, err := self.marshal(stored, self.getDeadline())
+        if err != nil {
+            return err
+        }
+        return bucket.Put([]byte(key), value)
+    })
+    if err == keyExpired {
+        self.removeExpired([][]byte{[]byte(key)})
+        err = keyNotExists
+    }
+    if err != nil {
+        logger.WithError(err).Error("update proxy error")
+    }
+    return err
+}
+
 func (self *boltDB) AddScore(proxy model.HttpProxy, score int) error {
     key := proxy.GetKey()
     data, err := self.GetByKey(key)
@@ -0,0 +1,62 @@
+package db
+
+import (
+    "io/ioutil"
+    "os"
+    "testing"
+    "time"
+
+    "github.com/phpgao/proxy_pool/model"
+)
+
+func TestBoltExpire(t *testing.T) {
+    dir, err := ioutil.TempDir("", "proxy_pool")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    store := &boltDB{DataDir: dir, KeyExpire: 3600}
+    if err := store.Init(); err != nil {
+        t.Fatal(err)
+    }
+    defer store.Close()
+
+    alive := model.HttpProxy{Ip: "1.1.1.1", Port: "80", Schema: "http", Score: 60}
+    dead := model.HttpProxy{Ip: "2.2.2.2", Port: "80", Schema: "http", Score: 60,
+        ExpiresAt: time.Now().Add(-time.Minute).Unix()}
+    // the expired proxy sorts first, it must not hide the other one
+    if dead.GetKey() > alive.GetKey() {
+        dead.Ip, alive.Ip = alive.Ip, dead.Ip
+    }
+    if !store.Add(dead) || !store.Add(alive) {
+        t.Fatal("add failed")
+    }
+
+    all := store.GetAll()
+    if len(all) != 1 || all[0].Ip != alive.Ip {
+        t.Fatalf("GetAll() = %v, want only %s", all, alive.Ip)
+    }
+    if store.Len() != 1 {
+        t.Errorf("expired proxy is not deleted, Len() = %d", store.Len())
+    }
+    for i := 0; i < 10; i++ {
+        p, err := store.Random()
+        if err != nil || p.Ip != alive.Ip {
+            t.Fatalf("Random() = %v, %v", p.Ip, err)
+        }
+    }
+    if store.Exists(dead) {
+        t.Error("expired proxy exists")
+    }
+
+    // an expired key is missing, adding it again stores the new proxy
+    dead.ExpiresAt = 0
+    dead.Score = 10
+    if !store.Add(dead) {
+        t.Fatal("add expired proxy again failed")
+    }
+    p, err := store.GetByKey(dead.GetKey())
+    if err != nil || p.Score != 10 {
+        t.Errorf("GetByKey() = %d, %v, want the new proxy", p.Score, err)
+    }
+}
@@ -13,6 +13,9 @@ import (
     "github.com/phpgao/proxy_pool/model"
 )
 
+// nodes sharing redis can not read credentials sealed with the random key of one of them
+var noCredentialKey = errors.New("CredentialKey is required to store credentials in redis")
+
 type redisDB struct {
     PrefixKey string
     client    *redis.Client
@@ -52,16 +55,19 @@ func (r *redisDB) GetProxyKey(proxy model.HttpProxy) string {
 
 func (r *redisDB) Add(proxy model.HttpProxy) bool {
     key := r.GetProxyKey(proxy)
+    // a proxy found again keeps its score, its history decides it, the expiry and credentials are renewed
     if !r.KeyExists(key) {
-        err := r.client.HMSet(key, proxy.GetProxyMap()).Err()
+        err := r.save(key, proxy)
         if err != nil {
             logger.WithError(err).Error("error add proxy")
             return false
         }
     } else {
-        err := r.AddScore(proxy, 10)
+        err := r.Update(proxy, func(stored *model.HttpProxy) {
+            stored.Renew(proxy)
+        })
         if err != nil {
-            logger.WithError(err).Error("error add Score")
+            logger.WithError(err).Error("error renew proxy")
             return false
         }
     }
@@ -89,6 +95,50 @@ func (r *redisDB) UpdateSchema(proxy model.HttpProxy) (err error) {
     return
 }
 
+func (r *redisDB) Update(proxy model.HttpProxy, fn func(*model.HttpProxy)) (err error) {
+    key := r.GetProxyKey(proxy)
+    r.lock.Lock()
+    defer r.lock.Unlock()
+
+    if !r.KeyExists(key) {
+        return errors.New("proxy not exists")
+    }
+    stored, err := r.load(r.client.HGetAll(key).Val())
+    if err != nil {
+        return
+    }
+    fn(&stored)
+    return r.save(key, stored)
+}
+
+// save writes the proxy with its credentials encrypted
+func (r *redisDB) save(key string, proxy model.HttpProxy) error {
+    if proxy.HasAuth() && config.CredentialKey == "" {
+        return noCredentialKey
+    }
+    sealed, err := proxy.Sealed()
+    if err != nil {
+        return err
+    }
+    err = r.client.HMSet(key, sealed.GetProxyMap()).Err()
+    if err == nil && proxy.Static {
+        // a proxy pinned after it was found by a spider drops its ttl
+        err = r.client.Persist(key).Err()
+    } else if err == nil && proxy.ExpiresAt > 0 {
+        // the ttl follows the expiry a provider renewed
+        err = r.client.ExpireAt(key, time.Unix(proxy.ExpiresAt, 0)).Err()
+    }
+    return err
+}
+
+func (r *redisDB) load(m map[string]string) (model.HttpProxy, error) {
+    proxy, err := model.Make(m)
+    if err != nil {
+        return proxy, err
+    }
+    return proxy, proxy.Unseal()
+}
+
 func (r *redisDB) Expire(key string, expiration time.Duration) error {
     r.lock.Lock()
     defer r.lock.Unlock()
@@ -114,7 +164,10 @@ func (r *redisDB) GetScore(p model.HttpProxy) int {
 
 func (r *redisDB) ExpireDefault(p model.HttpProxy) error {
     key := r.GetProxyKey(p)
-    if r.KeyExpire <= 0 {
+    if p.ExpiresAt > 0 {
+        return r.client.ExpireAt(key, time.Unix(p.ExpiresAt, 0)).Err()
+    }
+    if r.KeyExpire <= 0 || p.Static {
         return nil
     }
     return r.client.Expire(key, time.Duration(r.KeyExpire)*time.Second).Err()
@@ -176,6 +229,11 @@ func (r *redisDB) GetAll() (proxies []model.HttpProxy) {
             r.client.Del(key)
             continue
         }
+        // the record is fine, the key may be wrong, keep it for the nodes which can read it
+        if err = newProxy.Unseal(); err != nil {
+            logger.WithField("key", key).WithError(err).Warn("can not decrypt credentials, skip")
+            continue
+        }
         proxies = append(proxies, newProxy)
     }
     return proxies
@@ -283,7 +341,7 @@ func (r *redisDB) Random() (p model.HttpProxy, err error) {
     key := keys[rand.Intn(len(keys))]
     proxy := r.client.HGetAll(key).Val()
     //logger.WithField("proxy", proxy).Info("get all proxy")
-    newProxy, err := model.Make(proxy)
+    newProxy, err := r.load(proxy)
     if err != nil {
         return
     }
@@ -0,0 +1,134 @@
+package ipdb
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/phpgao/proxy_pool/util"
+)
+
+const (
+	ClassDatacenter  = "datacenter"
+	ClassMobile      = "mobile"
+	ClassResidential = "residential" // neither of the above
+	ClassUnknown     = ""            // no configured source covers the ip
+)
+
+var classifier atomic.Value
+
+// Classifier tells datacenter and mobile base station ips from the rest,
+// an ip none of its data covers is unknown rather than guessed
+type Classifier struct {
+	idc            *IDC
+	station        *BaseStation
+	language       string
+	datacenterAsns []asnRange
+	mobileAsns     []asnRange
+}
+
+type asnRange struct {
+	from, to int
+}
+
+// NewClassifier opens the idc and base station files, both can be left out
+func NewClassifier(conf *util.Config) (c *Classifier, err error) {
+	c = &Classifier{language: conf.IpipLanguage}
+	if conf.IdcFile != "" {
+		if c.idc, err = NewIDC(conf.IdcFile); err != nil {
+			return nil, err
+		}
+	}
+	if conf.BaseStationFile != "" {
+		if c.station, err = NewBaseStation(conf.BaseStationFile); err != nil {
+			return nil, err
+		}
+	}
+	if c.datacenterAsns, err = parseAsnRanges(conf.DatacenterAsns); err != nil {
+		return nil, err
+	}
+	if c.mobileAsns, err = parseAsnRanges(conf.MobileAsns); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// parseAsnRanges accepts single numbers and ranges like 64512-65534
+func parseAsnRanges(values []string) (ranges []asnRange, err error) {
+	for _, v := range values {
+		v = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(v)), "AS")
+		if v == "" {
+			continue
+		}
+		bounds := strings.SplitN(v, "-", 2)
+		var r asnRange
+		if r.from, err = strconv.Atoi(strings.TrimSpace(bounds[0])); err != nil {
+			return nil, fmt.Errorf("invalid asn: %s", v)
+		}
+		r.to = r.from
+		if len(bounds) == 2 {
+			if r.to, err = strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(bounds[1]), "AS")); err != nil {
+				return nil, fmt.Errorf("invalid asn: %s", v)
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return
+}
+
+func inAsnRanges(ranges []asnRange, asn int) bool {
+	for _, r := range ranges {
+		if asn >= r.from && asn <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// Class of the ip, asn is 0 when unknown; an ip at least one configured source covers is residential
+// when it is neither datacenter nor mobile, an ip no source covers is unknown
+func (c *Classifier) Class(ip net.IP, asn int) string {
+	covered := false
+	if c.station != nil {
+		info, err := c.station.FindInfo(ip.String(), c.language)
+		if err == nil {
+			covered = true
+			if strings.EqualFold(info.BaseStation, "BS") {
+				return ClassMobile
+			}
+		}
+	}
+	if c.idc != nil {
+		info, err := c.idc.FindInfo(ip.String(), c.language)
+		if err == nil {
+			covered = true
+			if info.IDC != "" {
+				return ClassDatacenter
+			}
+		}
+	}
+	if asn > 0 && (len(c.mobileAsns) > 0 || len(c.datacenterAsns) > 0) {
+		covered = true
+		if inAsnRanges(c.mobileAsns, asn) {
+			return ClassMobile
+		}
+		if inAsnRanges(c.datacenterAsns, asn) {
+			return ClassDatacenter
+		}
+	}
+	if covered {
+		return ClassResidential
+	}
+	return ClassUnknown
+}
+
+// Classify uses the classifier set up from the config
+func Classify(addr string, asn int) string {
+	ip := net.ParseIP(strings.TrimSpace(addr))
+	if ip == nil {
+		return ClassUnknown
+	}
+	return classifier.Load().(*Classifier).Class(ip, asn)
+}
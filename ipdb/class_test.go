@@ -0,0 +1,33 @@
+package ipdb
+
+import (
+	"net"
+	"testing"
+)
+
+func TestClass(t *testing.T) {
+	ip := net.ParseIP("1.2.3.4")
+	none := &Classifier{}
+	if got := none.Class(ip, 4134); got != ClassUnknown {
+		t.Errorf("no source: Class() = %q, want unknown", got)
+	}
+
+	asns := &Classifier{
+		datacenterAsns: []asnRange{{16509, 16509}},
+		mobileAsns:     []asnRange{{9808, 9808}},
+	}
+	tests := []struct {
+		asn  int
+		want string
+	}{
+		{16509, ClassDatacenter},
+		{9808, ClassMobile},
+		{4134, ClassResidential},
+		{0, ClassUnknown},
+	}
+	for _, tt := range tests {
+		if got := asns.Class(ip, tt.asn); got != tt.want {
+			t.Errorf("Class(AS%d) = %q, want %q", tt.asn, got, tt.want)
+		}
+	}
+}
@@ -1,5 +1,11 @@
 package ipdb
 
+import (
+	"time"
+
+	"github.com/phpgao/proxy_pool/util"
+)
+
 var (
 	Db *City
 )
@@ -12,4 +18,31 @@ func init() {
 	Db = &City{
 		reader: r,
 	}
+
+	if e = load(util.ServerConf); e != nil {
+		panic(e)
+	}
+}
+
+// the providers replaced by a reload are closed once the lookups in flight are surely over
+const closeDelay = time.Minute
+
+// load opens the providers and the classifier of the config and puts them in use together
+func load(conf *util.Config) error {
+	chain, err := NewChain(conf.GeoProviders, conf)
+	if err != nil {
+		return err
+	}
+	c, err := NewClassifier(conf)
+	if err != nil {
+		chain.Close()
+		return err
+	}
+	old, _ := geo.Load().(Chain)
+	SetGeo(chain)
+	classifier.Store(c)
+	if old != nil {
+		time.AfterFunc(closeDelay, old.Close)
+	}
+	return nil
 }
@@ -0,0 +1,114 @@
+package ipdb
+
+import (
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Mmdb is the provider of MaxMind GeoLite2/GeoIP2 databases,
+// any of the city, country and asn files can be left out
+type Mmdb struct {
+	Language string
+	city     *maxminddb.Reader
+	country  *maxminddb.Reader
+	asn      *maxminddb.Reader
+}
+
+type mmdbNames map[string]string
+
+type mmdbLocation struct {
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+	Country struct {
+		IsoCode string    `maxminddb:"iso_code"`
+		Names   mmdbNames `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names mmdbNames `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names mmdbNames `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+type mmdbAsn struct {
+	Number       int    `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// NewMmdb opens the given files, empty names are skipped
+func NewMmdb(city, country, asn, language string) (db *Mmdb, err error) {
+	db = &Mmdb{Language: language}
+	for _, f := range []struct {
+		name   string
+		reader **maxminddb.Reader
+	}{{city, &db.city}, {country, &db.country}, {asn, &db.asn}} {
+		if f.name == "" {
+			continue
+		}
+		*f.reader, err = maxminddb.Open(f.name)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if db.city == nil && db.country == nil && db.asn == nil {
+		return nil, ErrDatabaseError
+	}
+	return
+}
+
+func (db *Mmdb) Name() string {
+	return "mmdb"
+}
+
+func (db *Mmdb) Lookup(ip net.IP) (*Info, error) {
+	info := &Info{}
+	location := db.city
+	if location == nil {
+		location = db.country
+	}
+	if location != nil {
+		var r mmdbLocation
+		if err := location.Lookup(ip, &r); err != nil {
+			return nil, err
+		}
+		info.CountryName = r.Country.Names.get(db.Language)
+		info.CountryCode = r.Country.IsoCode
+		info.ContinentCode = r.Continent.Code
+		if len(r.Subdivisions) > 0 {
+			info.RegionName = r.Subdivisions[0].Names.get(db.Language)
+		}
+		info.CityName = r.City.Names.get(db.Language)
+	}
+	if db.asn != nil {
+		var r mmdbAsn
+		if err := db.asn.Lookup(ip, &r); err != nil {
+			return nil, err
+		}
+		info.Asn = r.Number
+		info.IspDomain = r.Organization
+	}
+	if info.CountryCode == "" && info.Asn == 0 {
+		return nil, ErrDataNotExists
+	}
+	return info, nil
+}
+
+func (db *Mmdb) Close() {
+	for _, r := range []*maxminddb.Reader{db.city, db.country, db.asn} {
+		if r != nil {
+			r.Close()
+		}
+	}
+}
+
+// get falls back to english when the name is not translated
+func (n mmdbNames) get(language string) string {
+	if v, ok := n[language]; ok {
+		return v
+	}
+	return n["en"]
+}
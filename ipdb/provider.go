@@ -0,0 +1,142 @@
+package ipdb
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/phpgao/proxy_pool/util"
+)
+
+// geo holds the Provider used by Lookup, swapped as a whole on reload
+var geo atomic.Value
+
+// Info is what a provider knows about an ip
+type Info struct {
+	CountryName   string
+	CountryCode   string
+	ContinentCode string
+	RegionName    string
+	CityName      string
+	IspDomain     string
+	Asn           int
+}
+
+// Provider looks up where an ip is
+type Provider interface {
+	Name() string
+	Lookup(ip net.IP) (*Info, error)
+}
+
+// Chain asks every provider in turn until one knows the ip
+type Chain []Provider
+
+func (c Chain) Name() string {
+	names := make([]string, len(c))
+	for i, p := range c {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Close releases the files of the providers which hold any
+func (c Chain) Close() {
+	for _, p := range c {
+		if closer, ok := p.(interface{ Close() }); ok {
+			closer.Close()
+		}
+	}
+}
+
+func (c Chain) Lookup(ip net.IP) (info *Info, err error) {
+	err = ErrDataNotExists
+	for _, p := range c {
+		info, err = p.Lookup(ip)
+		if err == nil {
+			return
+		}
+	}
+	return
+}
+
+// Lookup finds addr, both IPv4 and IPv6, with the configured providers
+func Lookup(addr string) (*Info, error) {
+	ip := net.ParseIP(strings.TrimSpace(addr))
+	if ip == nil {
+		return nil, ErrIPFormat
+	}
+	return GetGeo().Lookup(ip)
+}
+
+func GetGeo() Provider {
+	return geo.Load().(Provider)
+}
+
+// SetGeo replaces the provider, lookups in flight keep using the old one
+func SetGeo(p Provider) {
+	geo.Store(p)
+}
+
+// NewProvider opens the provider of the name, ipip or mmdb
+func NewProvider(name string, conf *util.Config) (Provider, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "ipip":
+		if conf.IpipFile == "" {
+			return &Ipip{City: Db, Language: conf.IpipLanguage}, nil
+		}
+		city, err := NewCity(conf.IpipFile)
+		if err != nil {
+			return nil, err
+		}
+		return &Ipip{City: city, Language: conf.IpipLanguage}, nil
+	case "mmdb":
+		return NewMmdb(conf.MmdbCity, conf.MmdbCountry, conf.MmdbAsn, conf.MmdbLanguage)
+	}
+	return nil, fmt.Errorf("unknown geo provider: %s", name)
+}
+
+// NewChain opens the providers in the given order
+func NewChain(names []string, conf *util.Config) (Chain, error) {
+	var c Chain
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		p, err := NewProvider(name, conf)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c = append(c, p)
+	}
+	if len(c) == 0 {
+		return nil, fmt.Errorf("no geo provider configured")
+	}
+	return c, nil
+}
+
+// Ipip is the provider of ipip.net databases
+type Ipip struct {
+	City     *City
+	Language string
+}
+
+func (p *Ipip) Name() string {
+	return "ipip"
+}
+
+func (p *Ipip) Lookup(ip net.IP) (*Info, error) {
+	ipInfo, err := p.City.FindInfo(ip.String(), p.Language)
+	if err != nil {
+		return nil, err
+	}
+	return &Info{
+		CountryName:   ipInfo.CountryName,
+		CountryCode:   ipInfo.CountryCode,
+		ContinentCode: ipInfo.ContinentCode,
+		RegionName:    ipInfo.RegionName,
+		CityName:      ipInfo.CityName,
+		IspDomain:     ipInfo.IspDomain,
+	}, nil
+}
@@ -0,0 +1,45 @@
+package ipdb
+
+import (
+	"os"
+	"time"
+
+	"github.com/phpgao/proxy_pool/util"
+)
+
+// Watch polls the configured database files and reloads the providers when any of them changes,
+// a file replaced by mv or cp is picked up alike
+func Watch() {
+	conf := util.ServerConf
+	if conf.GeoWatch <= 0 {
+		return
+	}
+	logger := util.GetLogger("ipdb")
+	files := []string{conf.IpipFile, conf.MmdbCity, conf.MmdbCountry, conf.MmdbAsn, conf.IdcFile, conf.BaseStationFile}
+	last := modTimes(files)
+	for range time.Tick(time.Duration(conf.GeoWatch) * time.Second) {
+		now := modTimes(files)
+		if now == last {
+			continue
+		}
+		// a file being written may not be readable yet, it will be tried again on the next tick
+		if err := load(conf); err != nil {
+			logger.WithError(err).Warn("reload ip database error")
+			continue
+		}
+		last = now
+		logger.WithField("providers", GetGeo().Name()).Info("ip database reloaded")
+	}
+}
+
+func modTimes(files []string) (r string) {
+	for _, f := range files {
+		if f == "" {
+			continue
+		}
+		if info, err := os.Stat(f); err == nil {
+			r += f + info.ModTime().String() + ";"
+		}
+	}
+	return
+}
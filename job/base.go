@@ -4,7 +4,9 @@ import (
     "errors"
     "fmt"
     "math/rand"
+    "net/http"
     "strings"
+    "sync"
     "time"
 
     "github.com/antchfx/htmlquery"
@@ -24,6 +26,9 @@ var (
     storeEngine        = db.GetDb()
     noProxy            = errors.New("no proxy")
     emptyResponse      = errors.New("empty resp")
+    // replay adjusts the transport of every request of the spiders when set,
+    // the fixture tests point it at an httptest server serving saved pages
+    replay func(*http.Transport)
 )
 
 func init() {
@@ -38,15 +43,24 @@ type Crawler interface {
     Retry() uint
     NeedRetry() bool
     Enabled() bool
+    TimeOut() int
+    GetReferer() string
     // url , if use proxy
     Fetch(string, bool) (string, error)
     SetProxyChan(chan<- *model.HttpProxy)
     GetProxyChan() chan<- *model.HttpProxy
     Parse(string) ([]*model.HttpProxy, error)
+    bind(Crawler)
 }
 
 type Spider struct {
     ch chan<- *model.HttpProxy
+    // self is the spider embedding this one, Fetch reads its settings and their overrides through it
+    self Crawler
+}
+
+func (s *Spider) bind(c Crawler) {
+    s.self = c
 }
 
 func (s *Spider) StartUrl() []string {
@@ -102,7 +116,7 @@ func (s *Spider) GetProxyChan() chan<- *model.HttpProxy {
 }
 
 func (s *Spider) RandomDelay() bool {
-    return true
+    return replay == nil
 }
 
 func (s *Spider) Retry() uint {
@@ -115,18 +129,31 @@ func (s *Spider) Fetch(proxyURL string, useProxy bool) (body string, err error)
         time.Sleep(time.Duration(rand.Intn(6)) * time.Second)
     }
 
+    timeout, referer := s.TimeOut(), s.GetReferer()
+    if s.self != nil {
+        timeout, referer = timeOut(s.self), refererOf(s.self)
+    }
+
     request := gorequest.New()
     contentType := "text/html; charset=utf-8"
     var superAgent *gorequest.SuperAgent
-    var resp gorequest.Response
-    var errs []error
     superAgent = request.Get(proxyURL).
         Set("User-Agent", util.GetRandomUA()).
         Set("Content-Type", contentType).
-        Set("Referer", s.GetReferer()).
+        Set("Referer", referer).
         Set("Pragma", `no-cache`).
-        Timeout(time.Duration(s.TimeOut()) * time.Second).SetDebug(util.ServerConf.DumpHttp)
+        Timeout(time.Duration(timeout) * time.Second).SetDebug(util.ServerConf.DumpHttp)
 
+    return s.end(superAgent, proxyURL, useProxy)
+}
+
+// end sends the request, through a random proxy of the pool if useProxy
+func (s *Spider) end(superAgent *gorequest.SuperAgent, proxyURL string, useProxy bool) (body string, err error) {
+    var resp gorequest.Response
+    var errs []error
+    if replay != nil {
+        replay(superAgent.Transport)
+    }
     if useProxy {
         var proxy model.HttpProxy
         proxy, err = storeEngine.Random()
@@ -149,18 +176,27 @@ func (s *Spider) Fetch(proxyURL string, useProxy bool) (body string, err error)
 
 func getProxy(s Crawler) {
     logger.WithField("spider", s.Name()).Debug("spider begin")
-    if !s.Enabled() {
+    if !IsEnabled(s) {
         logger.WithField("spider", s.Name()).Debug("spider is not enabled")
         return
     }
-    for _, url := range s.StartUrl() {
+    urls := startUrls(s)
+    // nothing to fetch, e.g. a provider whose target is met, is not a run
+    if len(urls) == 0 {
+        return
+    }
+    run := model.StartRun(s.Name())
+    var wg sync.WaitGroup
+    for _, url := range urls {
+        wg.Add(1)
         go func(proxySiteURL string, inputChan chan<- *model.HttpProxy) {
+            defer wg.Done()
             defer func() {
                 if r := recover(); r != nil {
                     logger.WithFields(log.Fields{
                         "url":   proxySiteURL,
                         "fatal": r,
-                        "from": s.Name(),
+                        "from":  s.Name(),
                     }).Error("recover from error while fetching")
                 }
             }()
@@ -174,7 +210,9 @@ func getProxy(s Crawler) {
                     logger.WithFields(log.Fields{"attempts": attempts, "site": proxySiteURL}).Debug("fetching proxy site")
 
                     var err error
-                    if !validator.CanDo() {
+                    // a full pool still takes the proxies of a category below its target, Wash makes room,
+                    // a provider only fills its own Target whatever the free proxies in the pool
+                    if _, paid := s.(*provider); !paid && !validator.CanDo() && !validator.Wanted(s.Name()) {
                         return MaxProxyReachedErr
                     }
 
@@ -184,17 +222,21 @@ func getProxy(s Crawler) {
                         withProxy = true
                     }
 
+                    run.Fetch()
                     resp, err := s.Fetch(proxySiteURL, withProxy)
                     if err != nil {
+                        run.HttpError()
                         return err
                     }
 
                     if resp == "" {
+                        run.HttpError()
                         return emptyResponse
                     }
 
                     newProxies, err = s.Parse(resp)
                     if err != nil {
+                        run.ParseError()
                         return err
                     }
 
@@ -204,7 +246,7 @@ func getProxy(s Crawler) {
 
                     return nil
                 },
-                retry.Attempts(s.Retry()),
+                retry.Attempts(retryOf(s)),
                 retry.RetryIf(func(err error) bool {
                     // should give up
                     if errors.Is(err, MaxProxyReachedErr) || errors.Is(err, noProxy) {
@@ -233,15 +275,19 @@ func getProxy(s Crawler) {
                     continue
                 }
                 tmpMap[newProxy.GetKey()] = 1
+                run.Emitted(1)
                 newProxy.From = s.Name()
                 if newProxy.Score == 0 {
                     newProxy.Score = util.ServerConf.DefaultScore
                 }
                 if model.FilterProxy(newProxy) {
+                    run.Filtered()
                     inputChan <- newProxy
                 }
             }
         }(url, s.GetProxyChan())
     }
+    wg.Wait()
+    run.Finish()
 
 }
@@ -0,0 +1,333 @@
+package job
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/parnurzeal/gorequest"
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v2"
+
+	"github.com/phpgao/proxy_pool/model"
+	"github.com/phpgao/proxy_pool/util"
+)
+
+const (
+	ModeXpath = "xpath"
+	ModeRegex = "regex"
+	ModeJson  = "json"
+	ModeLines = "lines"
+)
+
+// SpiderDef describes a spider in a yml or json file of SpiderDir
+type SpiderDef struct {
+	Name      string            `yaml:"name" json:"name"`
+	StartUrls []string          `yaml:"start_urls" json:"start_urls"`
+	Cron      string            `yaml:"cron" json:"cron"`
+	Referer   string            `yaml:"referer" json:"referer"`
+	Headers   map[string]string `yaml:"headers" json:"headers"`
+	Method    string            `yaml:"method" json:"method"`
+	Body      string            `yaml:"body" json:"body"`
+	Timeout   int               `yaml:"timeout" json:"timeout"`
+	Enabled   *bool             `yaml:"enabled" json:"enabled"`
+	// Mode is one of xpath, regex, json and lines
+	Mode string `yaml:"mode" json:"mode"`
+	// Rows selects a node per proxy in xpath mode, Ip and Port are then relative to it
+	Rows string `yaml:"rows" json:"rows"`
+	// Ip and Port are xpath in xpath mode and paths of a list item in json mode,
+	// without Port the ip is expected to be written as ip:port
+	Ip   string `yaml:"ip" json:"ip"`
+	Port string `yaml:"port" json:"port"`
+	// Pattern is the regex mode expression, named groups ip and port or a whole ip:port match
+	Pattern string `yaml:"pattern" json:"pattern"`
+	// List is the json path of the proxy list, empty for the whole response
+	List string `yaml:"list" json:"list"`
+}
+
+func init() {
+	defs, err := LoadSpiderDefs(util.ServerConf.SpiderDir)
+	if err != nil {
+		logger.WithError(err).WithField("dir", util.ServerConf.SpiderDir).Error("error load spider definitions")
+	}
+	for _, def := range defs {
+		s, err := newDeclarative(def)
+		if err != nil {
+			logger.WithError(err).WithField("spider", def.Name).Error("invalid spider definition")
+			continue
+		}
+		ListOfSpider = replaceSpider(ListOfSpider, s)
+	}
+}
+
+// replaceSpider puts s in place of the spider of the same name, a definition can fix or disable a built-in one
+func replaceSpider(list []Crawler, s Crawler) []Crawler {
+	for i, c := range list {
+		if c.Name() == s.Name() {
+			logger.WithField("spider", s.Name()).Info("spider replaced by definition")
+			list[i] = s
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// LoadSpiderDefs reads every definition of dir, a file holds a single definition or a list of them
+func LoadSpiderDefs(dir string) (defs []SpiderDef, err error) {
+	if dir == "" || !util.DirExists(dir) {
+		return
+	}
+	var files []string
+	for _, ext := range []string{"*.yml", "*.yaml", "*.json"} {
+		matches, err := filepath.Glob(filepath.Join(dir, ext))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	for _, file := range files {
+		d, err := parseSpiderDefs(file)
+		if err != nil {
+			logger.WithError(err).WithField("file", file).Error("error parse spider definition")
+			continue
+		}
+		defs = append(defs, d...)
+	}
+	return
+}
+
+// parseSpiderDefs parses yml and json alike, json being valid yml
+func parseSpiderDefs(file string) ([]SpiderDef, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var defs []SpiderDef
+	if err = yaml.Unmarshal(b, &defs); err == nil {
+		return defs, nil
+	}
+	var def SpiderDef
+	if err = yaml.Unmarshal(b, &def); err != nil {
+		return nil, err
+	}
+	return []SpiderDef{def}, nil
+}
+
+type declarative struct {
+	Spider
+	def     SpiderDef
+	pattern *regexp.Regexp
+}
+
+func newDeclarative(def SpiderDef) (*declarative, error) {
+	if def.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if def.Cron == "" {
+		def.Cron = "@every 5m"
+	}
+	if def.Method == "" {
+		def.Method = "GET"
+	}
+	if def.Referer == "" {
+		def.Referer = "https://www.baidu.com/"
+	}
+	s := &declarative{}
+	switch def.Mode {
+	case ModeXpath:
+		if def.Rows == "" || def.Ip == "" {
+			return nil, fmt.Errorf("rows and ip are required in xpath mode")
+		}
+	case ModeRegex:
+		if def.Pattern == "" {
+			def.Pattern = util.RegProxy
+		}
+		reg, err := regexp.Compile(def.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		s.pattern = reg
+	case ModeJson:
+		if def.Ip == "" {
+			def.Ip = "ip"
+		}
+	case ModeLines:
+	default:
+		return nil, fmt.Errorf("unknown mode: %q", def.Mode)
+	}
+	s.def = def
+	return s, nil
+}
+
+func (s *declarative) Run() {
+	getProxy(s)
+}
+
+func (s *declarative) StartUrl() []string {
+	return s.def.StartUrls
+}
+
+func (s *declarative) Cron() string {
+	return s.def.Cron
+}
+
+func (s *declarative) Name() string {
+	return s.def.Name
+}
+
+func (s *declarative) Enabled() bool {
+	return s.def.Enabled == nil || *s.def.Enabled
+}
+
+func (s *declarative) TimeOut() int {
+	if s.def.Timeout > 0 {
+		return s.def.Timeout
+	}
+	return s.Spider.TimeOut()
+}
+
+func (s *declarative) GetReferer() string {
+	return s.def.Referer
+}
+
+func (s *declarative) Fetch(proxyURL string, useProxy bool) (body string, err error) {
+	if s.RandomDelay() {
+		time.Sleep(time.Duration(rand.Intn(6)) * time.Second)
+	}
+
+	superAgent := gorequest.New().CustomMethod(strings.ToUpper(s.def.Method), proxyURL).
+		Set("User-Agent", util.GetRandomUA()).
+		Set("Referer", refererOf(s)).
+		Set("Pragma", `no-cache`).
+		Timeout(time.Duration(timeOut(s)) * time.Second).SetDebug(util.ServerConf.DumpHttp)
+	for k, v := range s.def.Headers {
+		superAgent.Set(k, v)
+	}
+	if s.def.Body != "" {
+		superAgent.SendString(s.def.Body)
+	}
+	return s.end(superAgent, proxyURL, useProxy)
+}
+
+func (s *declarative) Parse(body string) (proxies []*model.HttpProxy, err error) {
+	switch s.def.Mode {
+	case ModeXpath:
+		return s.parseXpath(body)
+	case ModeRegex:
+		return s.parseRegex(body), nil
+	case ModeJson:
+		return s.parseJson(body)
+	}
+	return s.parseLines(body), nil
+}
+
+func (s *declarative) parseXpath(body string) (proxies []*model.HttpProxy, err error) {
+	doc, err := htmlquery.Parse(strings.NewReader(body))
+	if err != nil {
+		return
+	}
+	for _, n := range htmlquery.Find(doc, s.def.Rows) {
+		ipNode := htmlquery.FindOne(n, s.def.Ip)
+		if ipNode == nil {
+			continue
+		}
+		ip := htmlquery.InnerText(ipNode)
+		var port string
+		if s.def.Port != "" {
+			portNode := htmlquery.FindOne(n, s.def.Port)
+			if portNode == nil {
+				continue
+			}
+			port = htmlquery.InnerText(portNode)
+		}
+		if p := newCandidate(ip, port); p != nil {
+			proxies = append(proxies, p)
+		}
+	}
+	return
+}
+
+func (s *declarative) parseRegex(body string) (proxies []*model.HttpProxy) {
+	var ipIndex, portIndex int
+	for i, name := range s.pattern.SubexpNames() {
+		switch name {
+		case "ip":
+			ipIndex = i
+		case "port":
+			portIndex = i
+		}
+	}
+	for _, m := range s.pattern.FindAllStringSubmatch(body, -1) {
+		var p *model.HttpProxy
+		if ipIndex > 0 && portIndex > 0 {
+			p = newCandidate(m[ipIndex], m[portIndex])
+		} else {
+			p = newCandidate(m[0], "")
+		}
+		if p != nil {
+			proxies = append(proxies, p)
+		}
+	}
+	return
+}
+
+func (s *declarative) parseJson(body string) (proxies []*model.HttpProxy, err error) {
+	if !gjson.Valid(body) {
+		return nil, fmt.Errorf("invalid json")
+	}
+	list := gjson.Parse(body)
+	if s.def.List != "" {
+		list = list.Get(s.def.List)
+	}
+	if !list.IsArray() {
+		return nil, fmt.Errorf("%q is not a list", s.def.List)
+	}
+	for _, item := range list.Array() {
+		var p *model.HttpProxy
+		if item.Type == gjson.String {
+			p = newCandidate(item.String(), "")
+		} else {
+			var port string
+			if s.def.Port != "" {
+				port = item.Get(s.def.Port).String()
+			}
+			p = newCandidate(item.Get(s.def.Ip).String(), port)
+		}
+		if p != nil {
+			proxies = append(proxies, p)
+		}
+	}
+	return
+}
+
+func (s *declarative) parseLines(body string) (proxies []*model.HttpProxy) {
+	for _, line := range strings.Split(body, "\n") {
+		if p := newCandidate(line, ""); p != nil {
+			proxies = append(proxies, p)
+		}
+	}
+	return
+}
+
+// newCandidate builds a proxy from an ip and a port, or from ip:port when port is empty
+func newCandidate(ip, port string) *model.HttpProxy {
+	ip, port = strings.TrimSpace(ip), strings.TrimSpace(port)
+	if port == "" {
+		var ok bool
+		if ip, port, ok = util.SplitProxy(ip); !ok {
+			return nil
+		}
+	}
+	if ip == "" || port == "" {
+		return nil
+	}
+	return &model.HttpProxy{
+		Ip:   ip,
+		Port: port,
+	}
+}
@@ -0,0 +1,159 @@
+package job
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/phpgao/proxy_pool/model"
+)
+
+func TestParseSpiderDefs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spiders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name  string
+		file  string
+		body  string
+		names []string
+	}{
+		{"single yml", "one.yml", "name: one\nmode: lines\nstart_urls:\n  - http://example.com/\n", []string{"one"}},
+		{"list yml", "list.yaml", "- name: one\n  mode: lines\n- name: two\n  mode: regex\n", []string{"one", "two"}},
+		{"single json", "one.json", `{"name":"one","mode":"json","list":"data"}`, []string{"one"}},
+		{"list json", "list.json", `[{"name":"one","mode":"json"},{"name":"two","mode":"lines"}]`, []string{"one", "two"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(dir, tt.file)
+			if err := ioutil.WriteFile(file, []byte(tt.body), 0644); err != nil {
+				t.Fatal(err)
+			}
+			defs, err := parseSpiderDefs(file)
+			if err != nil {
+				t.Fatalf("parseSpiderDefs() error = %v", err)
+			}
+			var names []string
+			for _, def := range defs {
+				names = append(names, def.Name)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("parseSpiderDefs() names = %v, want %v", names, tt.names)
+			}
+		})
+	}
+
+	broken := filepath.Join(dir, "broken.yml")
+	if err := ioutil.WriteFile(broken, []byte("name: [one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseSpiderDefs(broken); err == nil {
+		t.Error("parseSpiderDefs(broken) expected an error")
+	}
+}
+
+func TestNewDeclarative(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     SpiderDef
+		wantErr bool
+	}{
+		{"no name", SpiderDef{Mode: ModeLines}, true},
+		{"no mode", SpiderDef{Name: "s"}, true},
+		{"unknown mode", SpiderDef{Name: "s", Mode: "css"}, true},
+		{"xpath without rows", SpiderDef{Name: "s", Mode: ModeXpath, Ip: "./td[1]"}, true},
+		{"xpath without ip", SpiderDef{Name: "s", Mode: ModeXpath, Rows: "//tr"}, true},
+		{"bad pattern", SpiderDef{Name: "s", Mode: ModeRegex, Pattern: "(ip"}, true},
+		{"xpath", SpiderDef{Name: "s", Mode: ModeXpath, Rows: "//tr", Ip: "./td[1]"}, false},
+		{"regex", SpiderDef{Name: "s", Mode: ModeRegex}, false},
+		{"json", SpiderDef{Name: "s", Mode: ModeJson}, false},
+		{"lines", SpiderDef{Name: "s", Mode: ModeLines}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newDeclarative(tt.def)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newDeclarative() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if s.Cron() != "@every 5m" || s.def.Method != "GET" || s.GetReferer() == "" || !s.Enabled() {
+				t.Errorf("newDeclarative() defaults not applied: %+v", s.def)
+			}
+		})
+	}
+}
+
+func TestDeclarativeParse(t *testing.T) {
+	want := []model.HttpProxy{{Ip: "1.2.3.4", Port: "8080"}, {Ip: "5.6.7.8", Port: "3128"}}
+	tests := []struct {
+		name string
+		def  SpiderDef
+		body string
+	}{
+		{
+			name: "xpath",
+			def:  SpiderDef{Mode: ModeXpath, Rows: "//table/tbody/tr", Ip: "./td[1]", Port: "./td[2]"},
+			body: `<table><tr><td>1.2.3.4</td><td>8080</td></tr><tr><td>5.6.7.8</td><td> 3128 </td></tr><tr><td>ip</td></tr></table>`,
+		},
+		{
+			name: "xpath ip:port",
+			def:  SpiderDef{Mode: ModeXpath, Rows: "//li", Ip: "."},
+			body: `<ul><li>1.2.3.4:8080</li><li>5.6.7.8:3128</li><li>none</li></ul>`,
+		},
+		{
+			name: "regex",
+			def:  SpiderDef{Mode: ModeRegex},
+			body: `<p>1.2.3.4:8080</p><p>5.6.7.8:3128</p>`,
+		},
+		{
+			name: "regex groups",
+			def:  SpiderDef{Mode: ModeRegex, Pattern: `(?P<ip>[\d.]+)</td><td>(?P<port>\d+)`},
+			body: `<td>1.2.3.4</td><td>8080</td><td>5.6.7.8</td><td>3128</td>`,
+		},
+		{
+			name: "json",
+			def:  SpiderDef{Mode: ModeJson, List: "data", Port: "port"},
+			body: `{"data":[{"ip":"1.2.3.4","port":8080},{"ip":"5.6.7.8","port":"3128"}]}`,
+		},
+		{
+			name: "json strings",
+			def:  SpiderDef{Mode: ModeJson},
+			body: `["1.2.3.4:8080","5.6.7.8:3128"]`,
+		},
+		{
+			name: "lines",
+			def:  SpiderDef{Mode: ModeLines},
+			body: "1.2.3.4:8080\r\n\n5.6.7.8:3128\nnot a proxy\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.def.Name = tt.name
+			s, err := newDeclarative(tt.def)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Parse(tt.body)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(proxyFields(got), want) {
+				t.Errorf("Parse() = %+v, want %+v", proxyFields(got), want)
+			}
+		})
+	}
+
+	s, _ := newDeclarative(SpiderDef{Name: "json", Mode: ModeJson, List: "data"})
+	for _, body := range []string{`{"data":`, `{"data":{}}`} {
+		if _, err := s.Parse(body); err == nil {
+			t.Errorf("Parse(%s) expected an error", body)
+		}
+	}
+}
@@ -9,6 +9,8 @@ func (s *proxyListsLine) StartUrl() []string {
 	return []string{
 		"http://www.proxylists.net/http.txt",
 		"http://www.proxylists.net/http_highanon.txt",
+		"http://www.proxylists.net/socks4.txt",
+		"http://www.proxylists.net/socks5.txt",
 	}
 }
 
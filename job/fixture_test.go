@@ -0,0 +1,270 @@
+package job
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// go test ./job -run TestRecordFixtures -record [-spider name]
+var (
+	record       = flag.Bool("record", false, "fetch the pages of the spiders from the live sites and save them as fixtures")
+	recordSpider = flag.String("spider", "", "only record the fixtures of this spider")
+)
+
+const fixtureDir = "testdata"
+
+// fixture is testdata/<spider>/fixture.json, the saved pages sit next to it
+type fixture struct {
+	// url => file of the saved response
+	Pages map[string]string `json:"pages"`
+	// what the spider finds in the pages, as ip:port
+	Expected []string `json:"expected"`
+	// the pages were written by hand to the parser instead of saved from the site,
+	// they only keep the parser working and miss a layout change until real ones are recorded
+	Synthetic bool `json:"synthetic,omitempty"`
+}
+
+func loadFixture(name string) (f fixture, err error) {
+	b, err := ioutil.ReadFile(filepath.Join(fixtureDir, name, "fixture.json"))
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, &f); err != nil {
+		return
+	}
+	pages := make(map[string]string, len(f.Pages))
+	for u, file := range f.Pages {
+		pages[urlKey(u)] = file
+	}
+	f.Pages = pages
+	return
+}
+
+// pageKey is how a request is looked up in Pages, the scheme tells which server it reached
+func pageKey(scheme string, r *http.Request) string {
+	return urlKey(scheme + "://" + r.Host + r.RequestURI)
+}
+
+// urlKey sorts the query as gorequest does before sending it
+func urlKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.RawQuery = u.Query().Encode()
+	return u.Scheme + "://" + u.Host + u.RequestURI()
+}
+
+// startReplay points every request of the spiders at local servers which call handle,
+// https requests reach the second one in plain text
+func startReplay(handle func(key string, w http.ResponseWriter, r *http.Request)) (stop func()) {
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(pageKey("http", r), w, r)
+	}))
+	tls := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(pageKey("https", r), w, r)
+	}))
+	replay = func(t *http.Transport) {
+		t.Proxy = nil
+		t.DialContext = nil
+		t.Dial = func(network, addr string) (net.Conn, error) {
+			return net.Dial("tcp", plain.Listener.Addr().String())
+		}
+		t.DialTLS = func(network, addr string) (net.Conn, error) {
+			return net.Dial("tcp", tls.Listener.Addr().String())
+		}
+	}
+	return func() {
+		replay = nil
+		plain.Close()
+		tls.Close()
+	}
+}
+
+// fetchPage gets a start url through the spider, zdy drives a browser and is given the saved page
+func fetchPage(s Crawler, f fixture, u string) (string, error) {
+	if _, ok := s.(*zdy); ok {
+		b, err := ioutil.ReadFile(filepath.Join(fixtureDir, s.Name(), f.Pages[urlKey(u)]))
+		return string(b), err
+	}
+	return s.Fetch(u, false)
+}
+
+func found(t *testing.T, s Crawler, body string) (r []string) {
+	proxies, err := s.Parse(body)
+	if err != nil {
+		t.Errorf("parse error: %v", err)
+	}
+	for _, p := range proxies {
+		r = append(r, net.JoinHostPort(strings.TrimSpace(p.Ip), strings.TrimSpace(p.Port)))
+	}
+	return
+}
+
+func uniq(s []string) []string {
+	m := make(map[string]bool)
+	r := []string{}
+	for _, v := range s {
+		if !m[v] {
+			m[v] = true
+			r = append(r, v)
+		}
+	}
+	sort.Strings(r)
+	return r
+}
+
+func TestSpiderFixtures(t *testing.T) {
+	for _, s := range ListOfSpider {
+		s := s
+		t.Run(s.Name(), func(t *testing.T) {
+			f, err := loadFixture(s.Name())
+			if err != nil {
+				t.Fatalf("no fixture (%v), record one with: go test ./job -run TestRecordFixtures -record -spider %s", err, s.Name())
+			}
+			stop := startReplay(func(key string, w http.ResponseWriter, r *http.Request) {
+				file, ok := f.Pages[key]
+				if !ok {
+					t.Errorf("no saved page for %s", key)
+					http.NotFound(w, r)
+					return
+				}
+				http.ServeFile(w, r, filepath.Join(fixtureDir, s.Name(), file))
+			})
+			defer stop()
+
+			var got []string
+			fetched := 0
+			for _, u := range s.StartUrl() {
+				// the pages left out of the fixture are not fetched
+				if _, ok := f.Pages[urlKey(u)]; !ok {
+					continue
+				}
+				fetched++
+				body, err := fetchPage(s, f, u)
+				if err != nil {
+					t.Errorf("fetch %s: %v", u, err)
+					continue
+				}
+				got = append(got, found(t, s, body)...)
+			}
+			if fetched == 0 {
+				t.Fatal("the fixture has none of the start urls")
+			}
+			if f.Synthetic {
+				t.Logf("hand-written pages, record the real ones with: go test ./job -run TestRecordFixtures -record -spider %s", s.Name())
+			}
+			if got, want := uniq(got), uniq(f.Expected); !reflect.DeepEqual(got, want) {
+				t.Errorf("found %d proxies, want %d\ngot:  %v\nwant: %v", len(got), len(want), got, want)
+			}
+		})
+	}
+}
+
+// TestRecordFixtures saves what the live sites answer now, the expected proxies are what the spiders find in it,
+// check them before committing
+func TestRecordFixtures(t *testing.T) {
+	if !*record {
+		t.Skip("pass -record to fetch the live sites")
+	}
+	for _, s := range ListOfSpider {
+		if *recordSpider != "" && s.Name() != *recordSpider {
+			continue
+		}
+		dir := filepath.Join(fixtureDir, s.Name())
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		f := fixture{Pages: map[string]string{}}
+		var lock sync.Mutex
+		save := func(key, contentType string, body []byte) {
+			lock.Lock()
+			defer lock.Unlock()
+			if _, ok := f.Pages[key]; ok {
+				return
+			}
+			ext := ".txt"
+			if strings.Contains(contentType, "html") {
+				ext = ".html"
+			} else if strings.Contains(contentType, "json") {
+				ext = ".json"
+			}
+			file := fmt.Sprintf("%02d%s", len(f.Pages), ext)
+			if err := ioutil.WriteFile(filepath.Join(dir, file), body, 0644); err != nil {
+				t.Error(err)
+				return
+			}
+			f.Pages[key] = file
+		}
+		stop := startReplay(func(key string, w http.ResponseWriter, r *http.Request) {
+			req, err := http.NewRequest(r.Method, key, r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			req.Header = r.Header
+			// left to the client, which then decompresses the answer
+			req.Header.Del("Accept-Encoding")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			if resp.StatusCode == http.StatusOK {
+				save(key, resp.Header.Get("Content-Type"), body)
+			}
+			w.WriteHeader(resp.StatusCode)
+			_, _ = w.Write(body)
+		})
+
+		for _, u := range s.StartUrl() {
+			body, err := s.Fetch(u, false)
+			if err != nil {
+				t.Logf("%s: fetch %s: %v", s.Name(), u, err)
+				continue
+			}
+			// zdy does not go through gorequest
+			if _, ok := s.(*zdy); ok {
+				save(urlKey(u), "text/html", []byte(body))
+			}
+			f.Expected = append(f.Expected, found(t, s, body)...)
+			// the replay turned the random delay off, be polite anyway
+			time.Sleep(time.Second)
+		}
+		stop()
+
+		f.Expected = uniq(f.Expected)
+		b, err := json.MarshalIndent(f, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(dir, "fixture.json"), append(b, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%s: %d pages, %d proxies", s.Name(), len(f.Pages), len(f.Expected))
+	}
+}
@@ -55,15 +55,13 @@ func (s *premProxy) Parse(body string) (proxies []*model.HttpProxy, err error) {
 	}
 	scriptUrl = fmt.Sprintf("https://premproxy.com%s", scriptUrl)
 
-	_, jsCode, errs := gorequest.New().Get(scriptUrl).
+	jsCode, err := s.end(gorequest.New().Get(scriptUrl).
 		Set("User-Agent", util.GetRandomUA()).
 		Set("Content-Type", "text/html; charset=utf-8").
-		Set("Referer", s.GetReferer()).
+		Set("Referer", refererOf(s)).
 		Set("Pragma", `no-cache`).
-		Timeout(time.Duration(s.TimeOut()) * time.Second).End()
-
-	if len(errs) > 0 {
-		err = errs[0]
+		Timeout(time.Duration(timeOut(s))*time.Second), scriptUrl, false)
+	if err != nil {
 		return
 	}
 
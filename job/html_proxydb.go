@@ -18,6 +18,7 @@ type proxyDb struct {
 func (s *proxyDb) StartUrl() []string {
 	return []string{
 		"http://proxydb.net/?protocol=http&protocol=https&country=",
+		"http://proxydb.net/?protocol=socks4&protocol=socks5&country=",
 	}
 }
 
@@ -56,7 +56,8 @@ func (s *proxyLists) Parse(body string) (proxies []*model.HttpProxy, err error)
 		}
 		port := htmlquery.InnerText(htmlquery.FindOne(n, "//td[2]"))
 		proxyType := htmlquery.InnerText(htmlquery.FindOne(n, "//td[3]"))
-		if proxyType == "Anonymous" || proxyType == "Transparent" {
+		// socks proxies are listed as Socks4/Socks5, the validator figures out the version
+		if proxyType == "Anonymous" || proxyType == "Transparent" || strings.HasPrefix(proxyType, "Socks") {
 			ip = strings.TrimSpace(ip)
 			ip = s.decodeIp(strings.TrimSpace(ip))
 			if port != "" {
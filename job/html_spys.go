@@ -3,7 +3,6 @@ package job
 import (
 	"fmt"
 	"github.com/antchfx/htmlquery"
-	"github.com/apex/log"
 	"github.com/parnurzeal/gorequest"
 	"github.com/phpgao/proxy_pool/model"
 	"github.com/phpgao/proxy_pool/util"
@@ -23,6 +22,7 @@ func (s *spys) StartUrl() []string {
 		"http://spys.one/en/anonymous-proxy-list/",
 		"http://spys.one/free-proxy-list/CHN/",
 		"http://spys.one/free-proxy-list/US/",
+		"http://spys.one/en/socks-proxy-list/",
 	}
 }
 
@@ -48,39 +48,15 @@ func (s *spys) Fetch(siteUrl string, useProxy bool) (body string, err error) {
 		time.Sleep(time.Duration(rand.Intn(5)) * time.Second)
 	}
 
-	request := gorequest.New()
-	var superAgent *gorequest.SuperAgent
-	var resp gorequest.Response
-	var errs []error
-	superAgent = request.Post(siteUrl).
+	superAgent := gorequest.New().Post(siteUrl).
 		Set("User-Agent", util.GetRandomUA()).
 		Set("Content-Type", `text/html; charset=utf-8`).
-		Set("Referer", s.GetReferer()).
+		Set("Referer", refererOf(s)).
 		Set("Pragma", `no-cache`).
 		Send("xpp=2&xf1=1&xf2=0&xf4=0&xf5=1").
-		Timeout(time.Duration(s.TimeOut()) * time.Second).SetDebug(util.ServerConf.DumpHttp)
+		Timeout(time.Duration(timeOut(s)) * time.Second).SetDebug(util.ServerConf.DumpHttp)
 
-	if useProxy {
-		var proxy model.HttpProxy
-		proxy, err = storeEngine.Random()
-		if err != nil {
-			return
-		}
-		p := fmt.Sprintf("http://%s:%s", proxy.Ip, proxy.Port)
-		logger.WithFields(log.Fields{
-			"proxy": p,
-			"url":   siteUrl,
-		}).Debug("with proxy")
-		resp, body, errs = superAgent.Proxy(p).End()
-	} else {
-		resp, body, errs = superAgent.End()
-	}
-	if err = s.checkErrAndStatus(errs, resp); err != nil {
-		return
-	}
-
-	body = strings.TrimSpace(body)
-	return
+	return s.end(superAgent, siteUrl, useProxy)
 }
 
 func (s *spys) Parse(body string) (proxies []*model.HttpProxy, err error) {
@@ -72,10 +72,15 @@ func (s *coolProxy) Parse(body string) (proxies []*model.HttpProxy, err error) {
 	})
 
 	for _, proxy := range coolProxies {
+		// cool-proxy only tells anonymous or not, the validator grades it later
+		anonymous := model.AnonymousTransparent
+		if proxy.Anonymous == 1 {
+			anonymous = model.AnonymousAnonymous
+		}
 		proxies = append(proxies, &model.HttpProxy{
 			Ip:        proxy.IP,
 			Port:      strconv.Itoa(proxy.Port),
-			Anonymous: proxy.Anonymous,
+			Anonymous: anonymous,
 		})
 	}
 	return
@@ -46,6 +46,7 @@ var ListOfSpider = []Crawler{
 func GetSpiders(ch chan<- *model.HttpProxy) []Crawler {
 	for _, v := range ListOfSpider {
 		v.SetProxyChan(ch)
+		v.bind(v)
 	}
 	return ListOfSpider
 }
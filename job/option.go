@@ -0,0 +1,110 @@
+package job
+
+import (
+	"sync"
+
+	"github.com/phpgao/proxy_pool/util"
+)
+
+// options are the overrides of the Spiders config and the api, by spider name
+var (
+	options     = make(map[string]util.SpiderOption)
+	optionsLock sync.RWMutex
+)
+
+func init() {
+	for _, o := range util.ServerConf.Spiders {
+		if o.Name == "" {
+			continue
+		}
+		SetOption(o)
+	}
+}
+
+// GetOption returns the override of a spider
+func GetOption(name string) (o util.SpiderOption, ok bool) {
+	optionsLock.RLock()
+	defer optionsLock.RUnlock()
+	o, ok = options[name]
+	return
+}
+
+// SetOption merges o into the override of the spider o.Name, the zero fields of o leave the current ones as they are
+func SetOption(o util.SpiderOption) util.SpiderOption {
+	optionsLock.Lock()
+	defer optionsLock.Unlock()
+	cur := options[o.Name]
+	cur.Name = o.Name
+	if o.Enabled != nil {
+		enabled := *o.Enabled
+		cur.Enabled = &enabled
+	}
+	if o.Cron != "" {
+		cur.Cron = o.Cron
+	}
+	if o.Timeout > 0 {
+		cur.Timeout = o.Timeout
+	}
+	if o.Retry > 0 {
+		cur.Retry = o.Retry
+	}
+	if o.Referer != "" {
+		cur.Referer = o.Referer
+	}
+	if len(o.StartUrls) > 0 {
+		cur.StartUrls = append([]string(nil), o.StartUrls...)
+	}
+	options[o.Name] = cur
+	return cur
+}
+
+// DeleteOption gives the spider its own settings back
+func DeleteOption(name string) {
+	optionsLock.Lock()
+	defer optionsLock.Unlock()
+	delete(options, name)
+}
+
+// IsEnabled tells if the spider runs, the override comes first
+func IsEnabled(c Crawler) bool {
+	if o, ok := GetOption(c.Name()); ok && o.Enabled != nil {
+		return *o.Enabled
+	}
+	return c.Enabled()
+}
+
+// CronOf is the schedule of the spider
+func CronOf(c Crawler) string {
+	if o, ok := GetOption(c.Name()); ok && o.Cron != "" {
+		return o.Cron
+	}
+	return c.Cron()
+}
+
+func startUrls(c Crawler) []string {
+	if o, ok := GetOption(c.Name()); ok && len(o.StartUrls) > 0 {
+		return o.StartUrls
+	}
+	return c.StartUrl()
+}
+
+func retryOf(c Crawler) uint {
+	if o, ok := GetOption(c.Name()); ok && o.Retry > 0 {
+		return uint(o.Retry)
+	}
+	return c.Retry()
+}
+
+func timeOut(c Crawler) int {
+	if o, ok := GetOption(c.Name()); ok && o.Timeout > 0 {
+		return o.Timeout
+	}
+	return c.TimeOut()
+}
+
+func refererOf(c Crawler) string {
+	if o, ok := GetOption(c.Name()); ok && o.Referer != "" {
+		return o.Referer
+	}
+	return c.GetReferer()
+}
@@ -0,0 +1,231 @@
+package job
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/parnurzeal/gorequest"
+	"github.com/tidwall/gjson"
+
+	"github.com/phpgao/proxy_pool/model"
+	"github.com/phpgao/proxy_pool/util"
+)
+
+func init() {
+	for _, conf := range util.ServerConf.ProxyProviders {
+		ListOfSpider = append(ListOfSpider, newProvider(conf))
+	}
+}
+
+// provider calls the api of a paid proxy service, only when it has less proxies in the pool than the target
+type provider struct {
+	Spider
+	conf util.ProxyProvider
+}
+
+func newProvider(conf util.ProxyProvider) *provider {
+	if conf.Method == "" {
+		conf.Method = "GET"
+	}
+	if conf.Format == "" {
+		conf.Format = "json"
+	}
+	if conf.IpField == "" {
+		conf.IpField = "ip"
+	}
+	if conf.PortField == "" {
+		conf.PortField = "port"
+	}
+	if conf.ExpireLayout == "" {
+		conf.ExpireLayout = "2006-01-02 15:04:05"
+	}
+	if conf.Target <= 0 {
+		conf.Target = 10
+	}
+	if conf.Cron == "" {
+		conf.Cron = "@every 1m"
+	}
+	return &provider{conf: conf}
+}
+
+func (s *provider) Run() {
+	getProxy(s)
+}
+
+func (s *provider) Cron() string {
+	return s.conf.Cron
+}
+
+func (s *provider) Name() string {
+	return s.conf.Name
+}
+
+// paid apis count every call, a failed one is tried again on the next tick
+func (s *provider) NeedRetry() bool {
+	return false
+}
+
+func (s *provider) Enabled() bool {
+	return s.conf.Name != "" && s.conf.Url != ""
+}
+
+// StartUrl asks for the missing proxies only, nothing when the target is met
+func (s *provider) StartUrl() []string {
+	need := s.conf.Target - s.count()
+	if need <= 0 {
+		logger.WithField("spider", s.Name()).Debug("provider target reached")
+		return nil
+	}
+	return []string{strings.Replace(s.conf.Url, "{count}", strconv.Itoa(need), -1)}
+}
+
+// count is how many proxies of the provider are alive in the pool
+func (s *provider) count() (n int) {
+	now := time.Now().Unix()
+	for _, p := range storeEngine.GetAll() {
+		if p.From == s.Name() && (p.ExpiresAt == 0 || p.ExpiresAt > now) {
+			n++
+		}
+	}
+	return
+}
+
+// Fetch never goes through a proxy, providers often only accept whitelisted ips
+func (s *provider) Fetch(apiURL string, useProxy bool) (body string, err error) {
+	need := s.conf.Target - s.count()
+	superAgent := gorequest.New().CustomMethod(strings.ToUpper(s.conf.Method), apiURL).
+		Set("User-Agent", util.GetRandomUA()).
+		Timeout(time.Duration(timeOut(s)) * time.Second).SetDebug(util.ServerConf.DumpHttp)
+	for _, h := range s.conf.Headers {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) == 2 {
+			superAgent.Set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+		}
+	}
+	if s.conf.Body != "" {
+		superAgent.SendString(strings.Replace(s.conf.Body, "{count}", strconv.Itoa(need), -1))
+	}
+	return s.end(superAgent, apiURL, false)
+}
+
+func (s *provider) Parse(body string) (proxies []*model.HttpProxy, err error) {
+	if s.conf.Format == "text" {
+		proxies = s.parseText(body)
+	} else {
+		proxies, err = s.parseJson(body)
+		if err != nil {
+			logger.WithError(err).WithFields(log.Fields{
+				"spider": s.Name(),
+				"body":   body,
+			}).Debug("error parse provider response")
+			return
+		}
+	}
+	for _, p := range proxies {
+		if p.Username == "" && p.Password == "" {
+			p.Username = s.conf.Username
+			p.Password = s.conf.Password
+		}
+		if p.ExpiresAt == 0 && s.conf.Ttl > 0 {
+			p.ExpiresAt = time.Now().Unix() + int64(s.conf.Ttl)
+		}
+	}
+	return
+}
+
+func (s *provider) parseText(body string) (proxies []*model.HttpProxy) {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var p model.HttpProxy
+		if strings.Contains(line, "://") {
+			u, err := url.Parse(line)
+			if err != nil {
+				continue
+			}
+			p.Ip, p.Port = u.Hostname(), u.Port()
+			if u.User != nil {
+				p.Username = u.User.Username()
+				p.Password, _ = u.User.Password()
+			}
+		} else {
+			ip, port, ok := util.SplitProxy(line)
+			if !ok {
+				continue
+			}
+			p.Ip, p.Port = ip, port
+		}
+		proxies = append(proxies, &p)
+	}
+	return
+}
+
+func (s *provider) parseJson(body string) (proxies []*model.HttpProxy, err error) {
+	if !gjson.Valid(body) {
+		return nil, fmt.Errorf("invalid json")
+	}
+	list := gjson.Parse(body)
+	if s.conf.List != "" {
+		list = list.Get(s.conf.List)
+	}
+	if !list.IsArray() {
+		return nil, fmt.Errorf("%q is not a list", s.conf.List)
+	}
+	for _, item := range list.Array() {
+		p := &model.HttpProxy{
+			Ip:   item.Get(s.conf.IpField).String(),
+			Port: item.Get(s.conf.PortField).String(),
+		}
+		// a list of "ip:port" strings
+		if item.Type == gjson.String {
+			p.Ip = item.String()
+		}
+		if p.Port == "" {
+			if ip, port, ok := util.SplitProxy(p.Ip); ok {
+				p.Ip, p.Port = ip, port
+			}
+		}
+		if s.conf.UsernameField != "" {
+			p.Username = item.Get(s.conf.UsernameField).String()
+		}
+		if s.conf.PasswordField != "" {
+			p.Password = item.Get(s.conf.PasswordField).String()
+		}
+		if s.conf.ExpireField != "" {
+			p.ExpiresAt = s.parseExpire(item.Get(s.conf.ExpireField))
+		}
+		proxies = append(proxies, p)
+	}
+	return
+}
+
+// parseExpire accepts unix seconds or milliseconds, seconds left, or a time in ExpireLayout
+func (s *provider) parseExpire(v gjson.Result) int64 {
+	if !v.Exists() {
+		return 0
+	}
+	now := time.Now()
+	n, err := strconv.ParseInt(v.String(), 10, 64)
+	if err != nil {
+		t, err := time.ParseInLocation(s.conf.ExpireLayout, v.String(), time.Local)
+		if err != nil {
+			return 0
+		}
+		return t.Unix()
+	}
+	switch {
+	case n > 1e12:
+		return n / 1000
+	case n > 1e9:
+		return n
+	case n > 0:
+		return now.Unix() + n
+	}
+	return 0
+}
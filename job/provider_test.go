@@ -0,0 +1,107 @@
+package job
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/phpgao/proxy_pool/model"
+	"github.com/phpgao/proxy_pool/util"
+)
+
+// proxyFields keeps what the provider parsers set
+func proxyFields(proxies []*model.HttpProxy) (r []model.HttpProxy) {
+	for _, p := range proxies {
+		r = append(r, model.HttpProxy{Ip: p.Ip, Port: p.Port, Username: p.Username, Password: p.Password, ExpiresAt: p.ExpiresAt})
+	}
+	return
+}
+
+func TestProviderParseJson(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    util.ProxyProvider
+		body    string
+		want    []model.HttpProxy
+		wantErr bool
+	}{
+		{
+			name: "fields",
+			conf: util.ProxyProvider{List: "data.list", UsernameField: "user", PasswordField: "pass", ExpireField: "expire"},
+			body: `{"data":{"list":[{"ip":"1.2.3.4","port":8080,"user":"u","pass":"p","expire":1900000000}]}}`,
+			want: []model.HttpProxy{{Ip: "1.2.3.4", Port: "8080", Username: "u", Password: "p", ExpiresAt: 1900000000}},
+		},
+		{
+			name: "ip:port field",
+			conf: util.ProxyProvider{IpField: "addr"},
+			body: `[{"addr":"1.2.3.4:8080"}]`,
+			want: []model.HttpProxy{{Ip: "1.2.3.4", Port: "8080"}},
+		},
+		{
+			name: "list of strings",
+			body: `["1.2.3.4:8080","5.6.7.8:3128"]`,
+			want: []model.HttpProxy{{Ip: "1.2.3.4", Port: "8080"}, {Ip: "5.6.7.8", Port: "3128"}},
+		},
+		{name: "invalid json", body: `{"data":`, wantErr: true},
+		{name: "not a list", conf: util.ProxyProvider{List: "data"}, body: `{"data":{}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newProvider(tt.conf)
+			got, err := s.parseJson(tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJson() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(proxyFields(got), tt.want) {
+				t.Errorf("parseJson() = %+v, want %+v", proxyFields(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestProviderParseText(t *testing.T) {
+	body := "1.2.3.4:8080\r\n\n  http://u:p@5.6.7.8:3128  \nnot a proxy\nexample.com:80\n"
+	want := []model.HttpProxy{
+		{Ip: "1.2.3.4", Port: "8080"},
+		{Ip: "5.6.7.8", Port: "3128", Username: "u", Password: "p"},
+	}
+	got := proxyFields(newProvider(util.ProxyProvider{Format: "text"}).parseText(body))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseText() = %+v, want %+v", got, want)
+	}
+}
+
+func TestProviderParseExpire(t *testing.T) {
+	now := time.Now().Unix()
+	at := time.Date(2030, 1, 2, 3, 4, 5, 0, time.Local).Unix()
+	tests := []struct {
+		name   string
+		layout string
+		value  string
+		want   int64
+	}{
+		{"milliseconds", "", `1900000000123`, 1900000000},
+		{"seconds", "", `1900000000`, 1900000000},
+		{"seconds left", "", `600`, now + 600},
+		{"seconds left as string", "", `"600"`, now + 600},
+		{"default layout", "", `"2030-01-02 03:04:05"`, at},
+		{"layout", time.RFC3339, `"2030-01-02T03:04:05Z"`, time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC).Unix()},
+		{"zero", "", `0`, 0},
+		{"garbage", "", `"soon"`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newProvider(util.ProxyProvider{ExpireLayout: tt.layout})
+			got := s.parseExpire(gjson.Parse(tt.value))
+			// seconds left depend on the clock
+			if diff := got - tt.want; diff < 0 || diff > 1 {
+				t.Errorf("parseExpire(%s) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+	if got := newProvider(util.ProxyProvider{}).parseExpire(gjson.Get(`{}`, "expire")); got != 0 {
+		t.Errorf("parseExpire(missing) = %d, want 0", got)
+	}
+}
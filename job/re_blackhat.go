@@ -38,9 +38,9 @@ func (s *blackHat) Parse(body string) (proxies []*model.HttpProxy, err error) {
 	rs := reg.FindAllString(body, -1)
 
 	for _, proxy := range rs {
-		if strings.Contains(proxy, ":") {
-			proxyInfo := strings.Split(proxy, ":")
-
+		// the list is written as "ip port"
+		proxyInfo := strings.Fields(proxy)
+		if len(proxyInfo) == 2 {
 			proxies = append(proxies, &model.HttpProxy{
 				Ip:   proxyInfo[0],
 				Port: proxyInfo[1],
@@ -5,7 +5,6 @@ import (
 	"github.com/phpgao/proxy_pool/model"
 	"github.com/phpgao/proxy_pool/util"
 	"regexp"
-	"strings"
 )
 
 func (s *freeip) StartUrl() []string {
@@ -41,12 +40,10 @@ func (s *freeip) Parse(body string) (proxies []*model.HttpProxy, err error) {
 	rs := reg.FindAllString(body, -1)
 
 	for _, proxy := range rs {
-		if strings.Contains(proxy, ":") {
-			proxyInfo := strings.Split(proxy, ":")
-
+		if ip, port, ok := util.SplitProxy(proxy); ok {
 			proxies = append(proxies, &model.HttpProxy{
-				Ip:   proxyInfo[0],
-				Port: proxyInfo[1],
+				Ip:   ip,
+				Port: port,
 			})
 		}
 	}
@@ -6,7 +6,6 @@ import (
 	"github.com/phpgao/proxy_pool/util"
 	"net/url"
 	"regexp"
-	"strings"
 )
 
 var countryList = []string{
@@ -64,12 +63,10 @@ func (s *ip89) Parse(body string) (proxies []*model.HttpProxy, err error) {
 	rs := reg.FindAllString(body, -1)
 
 	for _, proxy := range rs {
-		if strings.Contains(proxy, ":") {
-			proxyInfo := strings.Split(proxy, ":")
-
+		if ip, port, ok := util.SplitProxy(proxy); ok {
 			proxies = append(proxies, &model.HttpProxy{
-				Ip:   proxyInfo[0],
-				Port: proxyInfo[1],
+				Ip:   ip,
+				Port: port,
 			})
 		}
 	}
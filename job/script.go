@@ -0,0 +1,337 @@
+package job
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/robertkrimen/otto"
+
+	"github.com/phpgao/proxy_pool/model"
+	"github.com/phpgao/proxy_pool/util"
+)
+
+var scriptTimeout = errors.New("script timeout")
+
+func init() {
+	for _, s := range LoadScripts(util.ServerConf.SpiderDir) {
+		ListOfSpider = replaceSpider(ListOfSpider, s)
+	}
+}
+
+// compiled is what a js file defines, it is replaced as a whole when the file changes
+type compiled struct {
+	script    *otto.Script
+	startUrls []string
+	cron      string
+	modTime   time.Time
+}
+
+// scriptSpider runs a js file exposing startUrls, cron and parse(body, helpers),
+// every parse gets a fresh vm as otto is not safe for concurrent use
+type scriptSpider struct {
+	Spider
+	name string
+	file string
+	code atomic.Value
+}
+
+// LoadScripts loads every js spider of dir, a broken file is logged and skipped
+func LoadScripts(dir string) (spiders []*scriptSpider) {
+	if dir == "" || !util.DirExists(dir) {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.js"))
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		s, err := newScriptSpider(file)
+		if err != nil {
+			logger.WithError(err).WithField("file", file).Error("error load js spider")
+			continue
+		}
+		spiders = append(spiders, s)
+	}
+	return
+}
+
+func newScriptSpider(file string) (*scriptSpider, error) {
+	c, name, err := compileScript(file)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	s := &scriptSpider{name: name, file: file}
+	s.code.Store(c)
+	return s, nil
+}
+
+// compileScript runs the file once to read its settings and make sure parse is defined,
+// a script stuck at the top level is stopped after the default spider timeout
+func compileScript(file string) (c *compiled, name string, err error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return
+	}
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	vm := otto.New()
+	script, err := vm.Compile(file, src)
+	if err != nil {
+		return
+	}
+	timer := interruptAfter(vm, time.Duration(util.ServerConf.Timeout)*time.Second)
+	defer timer.Stop()
+	defer catchTimeout(&err)
+	if _, err = vm.Run(script); err != nil {
+		return
+	}
+	parse, err := vm.Get("parse")
+	if err != nil {
+		return
+	}
+	if !parse.IsFunction() {
+		return nil, "", fmt.Errorf("parse is not a function")
+	}
+	c = &compiled{script: script, modTime: info.ModTime(), cron: "@every 5m"}
+	if v, _ := vm.Get("startUrls"); v.IsObject() {
+		for _, u := range jsList(v) {
+			c.startUrls = append(c.startUrls, u.String())
+		}
+	}
+	if len(c.startUrls) == 0 {
+		return nil, "", fmt.Errorf("startUrls is empty")
+	}
+	if v, _ := vm.Get("cron"); v.IsString() {
+		c.cron = v.String()
+	}
+	if v, _ := vm.Get("name"); v.IsString() {
+		name = v.String()
+	}
+	return
+}
+
+// reload compiles the file again if it changed, the old code is kept when the new one is broken,
+// it tells whether the cron changed
+func (s *scriptSpider) reload() (cronChanged bool) {
+	old := s.get()
+	info, err := os.Stat(s.file)
+	if err != nil || info.ModTime().Equal(old.modTime) {
+		return
+	}
+	c, name, err := compileScript(s.file)
+	if err != nil {
+		logger.WithError(err).WithField("file", s.file).Warn("reload js spider error, keep the old one")
+		return
+	}
+	if name != "" && name != s.name {
+		logger.WithField("file", s.file).Warn("the name of a js spider changes on restart only")
+	}
+	s.code.Store(c)
+	logger.WithField("spider", s.name).Info("js spider reloaded")
+	return c.cron != old.cron
+}
+
+func (s *scriptSpider) get() *compiled {
+	return s.code.Load().(*compiled)
+}
+
+func (s *scriptSpider) Run() {
+	getProxy(s)
+}
+
+func (s *scriptSpider) StartUrl() []string {
+	return s.get().startUrls
+}
+
+func (s *scriptSpider) Cron() string {
+	return s.get().cron
+}
+
+func (s *scriptSpider) Name() string {
+	return s.name
+}
+
+func (s *scriptSpider) Parse(body string) (proxies []*model.HttpProxy, err error) {
+	vm := otto.New()
+	// a script stuck in a loop is stopped after the spider timeout, the top level included
+	timer := interruptAfter(vm, time.Duration(timeOut(s))*time.Second)
+	defer timer.Stop()
+	defer catchTimeout(&err)
+	if _, err = vm.Run(s.get().script); err != nil {
+		return
+	}
+
+	helpers, err := scriptHelpers(vm)
+	if err != nil {
+		return
+	}
+	value, err := vm.Call("parse", nil, body, helpers)
+	if err != nil {
+		return
+	}
+	for _, item := range jsList(value) {
+		if !item.IsObject() {
+			continue
+		}
+		o := item.Object()
+		p := newCandidate(jsString(o, "ip"), jsString(o, "port"))
+		if p == nil {
+			continue
+		}
+		p.Schema = jsString(o, "schema")
+		proxies = append(proxies, p)
+	}
+	return
+}
+
+// interruptAfter makes what vm runs panic with scriptTimeout once timeout is over
+func interruptAfter(vm *otto.Otto, timeout time.Duration) *time.Timer {
+	vm.Interrupt = make(chan func(), 1)
+	return time.AfterFunc(timeout, func() {
+		vm.Interrupt <- func() {
+			panic(scriptTimeout)
+		}
+	})
+}
+
+// catchTimeout turns the panic of interruptAfter into an error, it must be deferred
+func catchTimeout(err *error) {
+	if r := recover(); r != nil {
+		if r != scriptTimeout {
+			panic(r)
+		}
+		*err = scriptTimeout
+	}
+}
+
+// scriptHelpers are the functions given to parse as its second argument
+func scriptHelpers(vm *otto.Otto) (*otto.Object, error) {
+	helpers, err := vm.Object(`({})`)
+	if err != nil {
+		return nil, err
+	}
+	funcs := map[string]interface{}{
+		// xpath returns the text of every node matching expr
+		"xpath": func(html, expr string) (texts []string) {
+			doc, err := htmlquery.Parse(strings.NewReader(html))
+			if err != nil {
+				return
+			}
+			for _, n := range htmlquery.Find(doc, expr) {
+				texts = append(texts, htmlquery.InnerText(n))
+			}
+			return
+		},
+		// xpathRows returns the text of the cols of every row, cols are relative to the row
+		"xpathRows": func(html, rows string, cols []string) (texts [][]string) {
+			doc, err := htmlquery.Parse(strings.NewReader(html))
+			if err != nil {
+				return
+			}
+			for _, n := range htmlquery.Find(doc, rows) {
+				row := make([]string, len(cols))
+				for i, col := range cols {
+					if c := htmlquery.FindOne(n, col); c != nil {
+						row[i] = htmlquery.InnerText(c)
+					}
+				}
+				texts = append(texts, row)
+			}
+			return
+		},
+		// regex returns every match with its groups
+		"regex": func(text, pattern string) [][]string {
+			reg, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil
+			}
+			return reg.FindAllStringSubmatch(text, -1)
+		},
+		"base64": func(s string) string {
+			b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+			if err != nil {
+				return ""
+			}
+			return string(b)
+		},
+		"log": func(msg string) {
+			logger.WithField("from", "js").Debug(msg)
+		},
+	}
+	for name, f := range funcs {
+		if err = helpers.Set(name, f); err != nil {
+			return nil, err
+		}
+	}
+	return helpers, nil
+}
+
+func jsList(v otto.Value) (items []otto.Value) {
+	if !v.IsObject() {
+		return
+	}
+	o := v.Object()
+	l, err := o.Get("length")
+	if err != nil {
+		return
+	}
+	n, err := l.ToInteger()
+	if err != nil {
+		return
+	}
+	for i := int64(0); i < n; i++ {
+		item, err := o.Get(strconv.FormatInt(i, 10))
+		if err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	return
+}
+
+func jsString(o *otto.Object, key string) string {
+	v, err := o.Get(key)
+	if err != nil || v.IsUndefined() || v.IsNull() {
+		return ""
+	}
+	return v.String()
+}
+
+// WatchScripts polls the js spiders and reloads the changed ones, onCron gets those whose cron changed,
+// a new file needs a restart as the scheduler only knows the spiders it started with
+func WatchScripts(onCron func(c Crawler)) {
+	if util.ServerConf.SpiderWatch <= 0 {
+		return
+	}
+	var scripts []*scriptSpider
+	for _, c := range ListOfSpider {
+		if s, ok := c.(*scriptSpider); ok {
+			scripts = append(scripts, s)
+		}
+	}
+	if len(scripts) == 0 {
+		return
+	}
+	for range time.Tick(time.Duration(util.ServerConf.SpiderWatch) * time.Second) {
+		for _, s := range scripts {
+			if s.reload() && onCron != nil {
+				onCron(s)
+			}
+		}
+	}
+}
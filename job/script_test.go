@@ -0,0 +1,193 @@
+package job
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/robertkrimen/otto"
+
+	"github.com/phpgao/proxy_pool/model"
+	"github.com/phpgao/proxy_pool/util"
+)
+
+const testScript = `
+var name = "js_test";
+var startUrls = ["http://example.com/"];
+var cron = "@every 10m";
+
+function parse(body, h) {
+	var proxies = [];
+	h.xpathRows(body, "//tr", ["./td[1]", "./td[2]"]).forEach(function (row) {
+		proxies.push({ip: row[0], port: row[1]});
+	});
+	h.regex(body, "(\\d+\\.\\d+\\.\\d+\\.\\d+):(\\d+)").forEach(function (m) {
+		proxies.push({ip: m[1], port: m[2], schema: "https"});
+	});
+	h.xpath(body, "//li").forEach(function (text) {
+		proxies.push({ip: h.base64(text)});
+	});
+	return proxies;
+}
+`
+
+// writeScript writes src to name in dir with a modification time of its own, so that reload sees it
+func writeScript(t *testing.T, dir, name, src string, modTime time.Time) string {
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestCompileScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scripts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		src     string
+		wantErr error
+	}{
+		{"ok", testScript, nil},
+		{"syntax error", "function parse(body, h) {", nil},
+		{"no parse", `var startUrls = ["http://example.com/"];`, nil},
+		{"parse not a function", `var startUrls = ["http://example.com/"]; var parse = 1;`, nil},
+		{"no start urls", `function parse(body, h) { return []; }`, nil},
+		{"stuck", `while (true) {}`, scriptTimeout},
+	}
+	defer func(timeout int) { util.ServerConf.Timeout = timeout }(util.ServerConf.Timeout)
+	util.ServerConf.Timeout = 1
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeScript(t, dir, "s.js", tt.src, time.Now())
+			c, name, err := compileScript(file)
+			if tt.name != "ok" {
+				if err == nil {
+					t.Fatal("compileScript() expected an error")
+				}
+				if tt.wantErr != nil && err != tt.wantErr {
+					t.Errorf("compileScript() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("compileScript() error = %v", err)
+			}
+			if name != "js_test" || c.cron != "@every 10m" || !reflect.DeepEqual(c.startUrls, []string{"http://example.com/"}) {
+				t.Errorf("compileScript() = %q %q %v", name, c.cron, c.startUrls)
+			}
+		})
+	}
+}
+
+func TestScriptParse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scripts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newScriptSpider(writeScript(t, dir, "s.js", testScript, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `<table><tr><td>1.2.3.4</td><td>8080</td></tr></table>` +
+		`<p>5.6.7.8:3128</p><ul><li>OS4xMC4xMS4xMjo4MA==</li></ul>`
+	proxies, err := s.Parse(body)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	var got []model.HttpProxy
+	for _, p := range proxies {
+		got = append(got, model.HttpProxy{Ip: p.Ip, Port: p.Port, Schema: p.Schema})
+	}
+	want := []model.HttpProxy{
+		{Ip: "1.2.3.4", Port: "8080"},
+		{Ip: "5.6.7.8", Port: "3128", Schema: "https"},
+		{Ip: "9.10.11.12", Port: "80"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %+v, want %+v", got, want)
+	}
+}
+
+func TestScriptParseTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scripts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(timeout int) { util.ServerConf.Timeout = timeout }(util.ServerConf.Timeout)
+	util.ServerConf.Timeout = 1
+
+	s, err := newScriptSpider(writeScript(t, dir, "s.js", testScript, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		src  string
+	}{
+		// compileScript stops such a file, the code is swapped in to reach the top level run of Parse
+		{"top level", `while (true) {} function parse(body, h) { return []; }`},
+		{"parse", `function parse(body, h) { while (true) {} }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := otto.New().Compile("", tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.code.Store(&compiled{script: script, startUrls: s.StartUrl(), cron: s.Cron()})
+			if _, err := s.Parse(""); err != scriptTimeout {
+				t.Errorf("Parse() error = %v, want %v", err, scriptTimeout)
+			}
+		})
+	}
+}
+
+func TestScriptReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scripts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	start := time.Now().Add(-time.Hour)
+	file := writeScript(t, dir, "s.js", testScript, start)
+	s, err := newScriptSpider(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.reload() {
+		t.Error("reload() of an unchanged file changed the cron")
+	}
+
+	writeScript(t, dir, "s.js", "function parse(body, h) {", start.Add(time.Minute))
+	if s.reload() || s.Cron() != "@every 10m" {
+		t.Errorf("reload() of a broken file replaced the old code, cron %q", s.Cron())
+	}
+	if proxies, err := s.Parse("1.2.3.4:8080"); err != nil || len(proxies) != 1 {
+		t.Errorf("Parse() after a broken reload = %v, %v", proxies, err)
+	}
+
+	writeScript(t, dir, "s.js", `var startUrls = ["http://example.org/"]; var cron = "@every 1m";`+
+		` function parse(body, h) { return []; }`, start.Add(2*time.Minute))
+	if !s.reload() {
+		t.Error("reload() did not report the cron change")
+	}
+	if s.Cron() != "@every 1m" || s.StartUrl()[0] != "http://example.org/" || s.Name() != "js_test" {
+		t.Errorf("reload() = %q %v %q", s.Cron(), s.StartUrl(), s.Name())
+	}
+}
@@ -0,0 +1 @@
+eval("$('.r4c1').html(8080);$('.x9d2').html(3128);")
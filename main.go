@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"github.com/phpgao/proxy_pool/ipdb"
+	"github.com/phpgao/proxy_pool/job"
 	"github.com/phpgao/proxy_pool/schedule"
 	"github.com/phpgao/proxy_pool/server"
 	"github.com/phpgao/proxy_pool/ulimit"
@@ -21,6 +23,23 @@ func main() {
 	// print welcome msg
 	ShowWelcome()
 
+	if util.ServerConf.JudgeOnly {
+		logger.Info("Running in judge only mode")
+		server.RunService()
+		return
+	}
+
+	go ipdb.Watch()
+	go job.WatchScripts(func(c job.Crawler) {
+		if s := schedule.GetScheduler(); s != nil {
+			if err := s.Reschedule(c.Name()); err != nil {
+				logger.WithError(err).WithField("spider", c.Name()).Error("error reschedule js spider")
+			}
+		}
+	})
+
+	validator.LoadStatic()
+
 	//wash cache
 	validator.Update()
 
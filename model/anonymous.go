@@ -0,0 +1,166 @@
+package model
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+const (
+    AnonymousUnknown = iota
+    AnonymousTransparent
+    AnonymousAnonymous
+    AnonymousElite
+)
+
+var (
+    anonymousNames = map[int]string{
+        AnonymousUnknown:     "unknown",
+        AnonymousTransparent: "transparent",
+        AnonymousAnonymous:   "anonymous",
+        AnonymousElite:       "elite",
+    }
+    // headers added by proxies which tell the target a proxy is in use
+    proxyHeaders = []string{
+        "Via",
+        "X-Forwarded-For",
+        "Forwarded",
+        "X-Real-Ip",
+        "X-Proxy-Id",
+        "Proxy-Connection",
+        "Client-Ip",
+    }
+    realIp     string
+    realIpLock sync.Mutex
+)
+
+// JudgeResult is what a judge echoes back about the request it received
+type JudgeResult struct {
+    Origin   string            `json:"origin"`
+    Headers  map[string]string `json:"headers"`
+    Protocol string            `json:"protocol,omitempty"`
+}
+
+// ParseAnonymous accepts a level name or number
+func ParseAnonymous(v string) (int, error) {
+    for level, name := range anonymousNames {
+        if strings.EqualFold(name, v) {
+            return level, nil
+        }
+    }
+    level, err := strconv.Atoi(v)
+    if err != nil || level < AnonymousUnknown || level > AnonymousElite {
+        return 0, fmt.Errorf("invalid anonymous level: %s", v)
+    }
+    return level, nil
+}
+
+func (p *HttpProxy) GetAnonymousName() string {
+    return anonymousNames[p.Anonymous]
+}
+
+func judge(client *http.Client, target string) (result JudgeResult, err error) {
+    resp, err := client.Get(target)
+    if err != nil {
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != 200 {
+        err = fmt.Errorf("http code %d", resp.StatusCode)
+        return
+    }
+    b, err := ioutil.ReadAll(resp.Body)
+    if err != nil {
+        return
+    }
+    body := strings.TrimSpace(string(b))
+    // plain text judges like ip.cip.cc only answer with the ip
+    if !strings.HasPrefix(body, "{") {
+        result.Origin = body
+        return
+    }
+    err = json.Unmarshal(b, &result)
+    return
+}
+
+// getRealIp asks the judge who we are without any proxy, the result is cached once known
+func getRealIp() string {
+    realIpLock.Lock()
+    defer realIpLock.Unlock()
+    if realIp != "" {
+        return realIp
+    }
+    result, err := judge(&http.Client{Timeout: time.Duration(config.ProxyTimeout) * time.Second}, config.GetJudgeUrl())
+    if err != nil {
+        logger.WithError(err).Warn("can not get real ip from judge")
+        return ""
+    }
+    realIp = strings.TrimSpace(strings.Split(result.Origin, ",")[0])
+    return realIp
+}
+
+// anonymousLevel grades what the judge saw, without our own ip a leak can not be told apart
+func (r JudgeResult) anonymousLevel(ourIp string) int {
+    ip := net.ParseIP(ourIp)
+    if ip == nil {
+        return AnonymousUnknown
+    }
+    if hasIp(r.Origin, ip) {
+        return AnonymousTransparent
+    }
+    for _, v := range r.Headers {
+        if hasIp(v, ip) {
+            return AnonymousTransparent
+        }
+    }
+    for k := range r.Headers {
+        for _, h := range proxyHeaders {
+            if strings.EqualFold(k, h) {
+                return AnonymousAnonymous
+            }
+        }
+    }
+    return AnonymousElite
+}
+
+// hasIp tells whether a list of addresses like "1.2.3.4, 5.6.7.8" or "for=1.2.3.4;proto=http" holds ip
+func hasIp(v string, ip net.IP) bool {
+    for _, part := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ';' }) {
+        part = strings.TrimSpace(part)
+        if i := strings.Index(part, "="); i >= 0 {
+            part = part[i+1:]
+        }
+        part = strings.Trim(part, `"`)
+        if host, _, err := net.SplitHostPort(part); err == nil {
+            part = host
+        }
+        if ip.Equal(net.ParseIP(strings.Trim(part, "[]"))) {
+            return true
+        }
+    }
+    return false
+}
+
+// TestAnonymous sends a request to the judge through the proxy and grades what leaked
+func (p *HttpProxy) TestAnonymous() (level int, err error) {
+    client := &http.Client{
+        Transport: p.GetHttpTransport(),
+        Timeout:   time.Duration(config.ProxyTimeout) * time.Second,
+    }
+    result, err := judge(client, config.GetJudgeUrl())
+    if err != nil {
+        return
+    }
+    if result.Headers == nil {
+        return AnonymousUnknown, errors.New("judge does not echo headers")
+    }
+    return result.anonymousLevel(getRealIp()), nil
+}
@@ -0,0 +1,27 @@
+package model
+
+import "testing"
+
+func TestAnonymousLevel(t *testing.T) {
+    tests := []struct {
+        name   string
+        result JudgeResult
+        ourIp  string
+        want   int
+    }{
+        {"no real ip", JudgeResult{Origin: "1.2.3.4", Headers: map[string]string{}}, "", AnonymousUnknown},
+        {"origin", JudgeResult{Origin: "1.2.3.4, 5.6.7.8", Headers: map[string]string{}}, "1.2.3.4", AnonymousTransparent},
+        {"forwarded for", JudgeResult{Origin: "5.6.7.8", Headers: map[string]string{"X-Forwarded-For": "9.9.9.9,1.2.3.4"}}, "1.2.3.4", AnonymousTransparent},
+        {"forwarded", JudgeResult{Origin: "5.6.7.8", Headers: map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=http`}}, "2001:db8::1", AnonymousTransparent},
+        {"prefix of another ip", JudgeResult{Origin: "11.2.3.45", Headers: map[string]string{"X-Forwarded-For": "1.2.3.45"}}, "1.2.3.4", AnonymousAnonymous},
+        {"via", JudgeResult{Origin: "5.6.7.8", Headers: map[string]string{"Via": "1.1 squid"}}, "1.2.3.4", AnonymousAnonymous},
+        {"elite", JudgeResult{Origin: "5.6.7.8", Headers: map[string]string{"Accept": "*/*"}}, "1.2.3.4", AnonymousElite},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := tt.result.anonymousLevel(tt.ourIp); got != tt.want {
+                t.Errorf("anonymousLevel() = %s, want %s", anonymousNames[got], anonymousNames[tt.want])
+            }
+        })
+    }
+}
@@ -0,0 +1,147 @@
+package model
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "io"
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+    "sync"
+
+    "github.com/phpgao/proxy_pool/util"
+)
+
+// stored credentials start with it, values without it were written in plain text
+const sealedPrefix = "enc:"
+
+const redacted = "******"
+
+var (
+    credentialKey     []byte
+    credentialKeyErr  error
+    credentialKeyOnce sync.Once
+    badSecret         = errors.New("invalid encrypted credential")
+)
+
+// getCredentialKey derives the key from CredentialKey,
+// or from a random secret kept in DataDir when it is not configured
+func getCredentialKey() ([]byte, error) {
+    credentialKeyOnce.Do(func() {
+        secret := config.CredentialKey
+        if secret == "" {
+            secret, credentialKeyErr = loadSecretFile(filepath.Join(config.DataDir, "credential.key"))
+            if credentialKeyErr != nil {
+                return
+            }
+        }
+        sum := sha256.Sum256([]byte(secret))
+        credentialKey = sum[:]
+    })
+    return credentialKey, credentialKeyErr
+}
+
+func loadSecretFile(name string) (string, error) {
+    if util.FileExists(name) {
+        b, err := ioutil.ReadFile(name)
+        return strings.TrimSpace(string(b)), err
+    }
+    b := make([]byte, 32)
+    if _, err := io.ReadFull(rand.Reader, b); err != nil {
+        return "", err
+    }
+    secret := hex.EncodeToString(b)
+    if err := ioutil.WriteFile(name, []byte(secret), 0600); err != nil {
+        return "", err
+    }
+    logger.WithField("file", name).Warn("CredentialKey is not set, created a random one, share it with other nodes of the same store")
+    return secret, nil
+}
+
+func getCipher() (cipher.AEAD, error) {
+    key, err := getCredentialKey()
+    if err != nil {
+        return nil, err
+    }
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return cipher.NewGCM(block)
+}
+
+func sealSecret(s string) (string, error) {
+    if s == "" || strings.HasPrefix(s, sealedPrefix) {
+        return s, nil
+    }
+    gcm, err := getCipher()
+    if err != nil {
+        return "", err
+    }
+    nonce := make([]byte, gcm.NonceSize())
+    if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+        return "", err
+    }
+    return sealedPrefix + base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(s), nil)), nil
+}
+
+func openSecret(s string) (string, error) {
+    if !strings.HasPrefix(s, sealedPrefix) {
+        return s, nil
+    }
+    data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, sealedPrefix))
+    if err != nil {
+        return "", err
+    }
+    gcm, err := getCipher()
+    if err != nil {
+        return "", err
+    }
+    if len(data) < gcm.NonceSize() {
+        return "", badSecret
+    }
+    plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+    if err != nil {
+        return "", badSecret
+    }
+    return string(plain), nil
+}
+
+func (p *HttpProxy) HasAuth() bool {
+    return p.Username != "" || p.Password != ""
+}
+
+// Sealed returns a copy of the proxy with the credentials encrypted, ready to be stored
+func (p HttpProxy) Sealed() (HttpProxy, error) {
+    var err error
+    if p.Username, err = sealSecret(p.Username); err != nil {
+        return p, err
+    }
+    p.Password, err = sealSecret(p.Password)
+    return p, err
+}
+
+// Unseal decrypts the credentials of a stored proxy
+func (p *HttpProxy) Unseal() (err error) {
+    if p.Username, err = openSecret(p.Username); err != nil {
+        return
+    }
+    p.Password, err = openSecret(p.Password)
+    return
+}
+
+// Redacted hides the credentials from callers who are not allowed to see them
+func (p HttpProxy) Redacted() HttpProxy {
+    if p.Username != "" {
+        p.Username = redacted
+    }
+    if p.Password != "" {
+        p.Password = redacted
+    }
+    return p
+}
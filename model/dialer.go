@@ -0,0 +1,186 @@
+package model
+
+import (
+    "bufio"
+    "context"
+    "crypto/tls"
+    "encoding/base64"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "golang.org/x/net/proxy"
+)
+
+var (
+    socks4Rejected  = errors.New("socks4 request rejected")
+    socks4BadReply  = errors.New("invalid socks4 reply")
+    socks4OnlyIpv4  = errors.New("socks4 only supports ipv4 targets")
+    connectRejected = errors.New("proxy refused connect")
+)
+
+// bufferedConn keeps the bytes read ahead while parsing the CONNECT response
+type bufferedConn struct {
+    net.Conn
+    r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+    return c.r.Read(b)
+}
+
+// Dial connects to addr through the proxy, the returned conn talks to addr directly
+func (p *HttpProxy) Dial(network, addr string, timeout time.Duration) (net.Conn, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+    return p.DialContext(ctx, network, addr)
+}
+
+func (p *HttpProxy) DialContext(ctx context.Context, network, addr string) (conn net.Conn, err error) {
+    switch p.Schema {
+    case SchemaSocks5:
+        return p.dialSocks5(ctx, network, addr)
+    case SchemaSocks4, SchemaSocks4a:
+        conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", p.GetProxyUrl())
+        if err != nil {
+            return
+        }
+        err = handshake(ctx, conn, func() error {
+            return p.socks4Handshake(conn, addr)
+        })
+    default:
+        conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", p.GetProxyUrl())
+        if err != nil {
+            return
+        }
+        err = handshake(ctx, conn, func() error {
+            if p.IsHttps() {
+                tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+                conn = tlsConn
+                if err := tlsConn.Handshake(); err != nil {
+                    return err
+                }
+            }
+            conn, err = p.connectHandshake(conn, addr)
+            return err
+        })
+    }
+    if err != nil && conn != nil {
+        _ = conn.Close()
+        conn = nil
+    }
+    return
+}
+
+// handshake runs fn with the deadline of ctx applied to conn, and clears it afterwards
+func handshake(ctx context.Context, conn net.Conn, fn func() error) error {
+    if deadline, ok := ctx.Deadline(); ok {
+        if err := conn.SetDeadline(deadline); err != nil {
+            return err
+        }
+    }
+    if err := fn(); err != nil {
+        return err
+    }
+    return conn.SetDeadline(time.Time{})
+}
+
+func (p *HttpProxy) dialSocks5(ctx context.Context, network, addr string) (net.Conn, error) {
+    var auth *proxy.Auth
+    if p.HasAuth() {
+        auth = &proxy.Auth{User: p.Username, Password: p.Password}
+    }
+    dialer, err := proxy.SOCKS5("tcp", p.GetProxyUrl(), auth, &net.Dialer{})
+    if err != nil {
+        return nil, err
+    }
+    return dialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+}
+
+func (p *HttpProxy) connectHandshake(conn net.Conn, addr string) (net.Conn, error) {
+    msg := fmt.Sprintf(ConnectCommand, http.MethodConnect, addr, "HTTP/1.1", addr)
+    if p.HasAuth() {
+        credential := base64.StdEncoding.EncodeToString([]byte(p.Username + ":" + p.Password))
+        msg = strings.TrimSuffix(msg, "\r\n") + "Proxy-Authorization: Basic " + credential + "\r\n\r\n"
+    }
+    if _, err := conn.Write([]byte(msg)); err != nil {
+        return conn, err
+    }
+    br := bufio.NewReader(conn)
+    resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+    if err != nil {
+        return conn, err
+    }
+    _ = resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return conn, fmt.Errorf("%w: %s", connectRejected, resp.Status)
+    }
+    if br.Buffered() > 0 {
+        return &bufferedConn{Conn: conn, r: br}, nil
+    }
+    return conn, nil
+}
+
+// socks4 and socks4a share the same request, 4a sends the hostname instead of a resolved ip
+func (p *HttpProxy) socks4Handshake(conn net.Conn, addr string) error {
+    host, portText, err := net.SplitHostPort(addr)
+    if err != nil {
+        return err
+    }
+    port, err := strconv.Atoi(portText)
+    if err != nil {
+        return err
+    }
+
+    req := []byte{4, 1, 0, 0}
+    binary.BigEndian.PutUint16(req[2:], uint16(port))
+    // socks4 has no password, the username goes in the userid field
+    userId := append([]byte(p.Username), 0)
+
+    ip := net.ParseIP(host)
+    if ip == nil && p.Schema == SchemaSocks4 {
+        addrs, err := net.LookupIP(host)
+        if err != nil {
+            return err
+        }
+        for _, a := range addrs {
+            if a.To4() != nil {
+                ip = a
+                break
+            }
+        }
+    }
+    if ip != nil {
+        if ip.To4() == nil {
+            return socks4OnlyIpv4
+        }
+        req = append(req, ip.To4()...)
+        req = append(req, userId...)
+    } else {
+        req = append(req, 0, 0, 0, 1)
+        req = append(req, userId...)
+        req = append(req, host...)
+        req = append(req, 0)
+    }
+
+    if _, err = conn.Write(req); err != nil {
+        return err
+    }
+    reply := make([]byte, 8)
+    if _, err = io.ReadFull(conn, reply); err != nil {
+        return err
+    }
+    if reply[0] != 0 {
+        return socks4BadReply
+    }
+    if reply[1] != 0x5a {
+        return socks4Rejected
+    }
+    return nil
+}
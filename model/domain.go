@@ -0,0 +1,129 @@
+package model
+
+import (
+    "net"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// DomainStat is how a proxy did against one target domain
+type DomainStat struct {
+    Success     int   `json:"success"`
+    Failure     int   `json:"failure"`
+    Latency     int   `json:"latency"` // average ms of the successful requests
+    LastSuccess int64 `json:"last_success"`
+    LastFailure int64 `json:"last_failure"`
+}
+
+// Merge adds the counters of other to s
+func (s DomainStat) Merge(other DomainStat) DomainStat {
+    if total := s.Success + other.Success; total > 0 {
+        s.Latency = (s.Latency*s.Success + other.Latency*other.Success) / total
+    }
+    s.Success += other.Success
+    s.Failure += other.Failure
+    if other.LastSuccess > s.LastSuccess {
+        s.LastSuccess = other.LastSuccess
+    }
+    if other.LastFailure > s.LastFailure {
+        s.LastFailure = other.LastFailure
+    }
+    return s
+}
+
+// NewDomainStat is the stat of a single request
+func NewDomainStat(success bool, latency time.Duration) DomainStat {
+    now := time.Now().Unix()
+    if success {
+        return DomainStat{Success: 1, Latency: int(latency / time.Millisecond), LastSuccess: now}
+    }
+    return DomainStat{Failure: 1, LastFailure: now}
+}
+
+// lastUsed is the unix time of the last request to the domain
+func (s DomainStat) lastUsed() int64 {
+    if s.LastSuccess > s.LastFailure {
+        return s.LastSuccess
+    }
+    return s.LastFailure
+}
+
+// maxDomainLength is the longest host name dns allows
+const maxDomainLength = 253
+
+// DomainOf returns the lower cased host of a url or a host:port, empty if it is not a valid host name or ip
+func DomainOf(target string) string {
+    host := target
+    if strings.Contains(target, "://") {
+        u, err := url.Parse(target)
+        if err != nil {
+            return ""
+        }
+        host = u.Host
+    }
+    if h, _, err := net.SplitHostPort(host); err == nil {
+        host = h
+    }
+    return normalizeDomain(host)
+}
+
+func normalizeDomain(host string) string {
+    host = strings.ToLower(strings.TrimSuffix(host, "."))
+    if host == "" || len(host) > maxDomainLength {
+        return ""
+    }
+    if net.ParseIP(host) != nil {
+        return host
+    }
+    for _, c := range host {
+        if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '.' && c != '_' {
+            return ""
+        }
+    }
+    return host
+}
+
+// MergeDomain adds stat to the domain, the proxy keeps at most DomainMax domains
+// and forgets the ones it has not used for the longest time
+func (p *HttpProxy) MergeDomain(domain string, stat DomainStat) {
+    domain = normalizeDomain(domain)
+    if domain == "" {
+        return
+    }
+    if p.Domains == nil {
+        p.Domains = make(map[string]DomainStat)
+    }
+    p.Domains[domain] = p.Domains[domain].Merge(stat)
+    for config.DomainMax > 0 && len(p.Domains) > config.DomainMax {
+        oldest := ""
+        for d, s := range p.Domains {
+            if d == domain {
+                continue
+            }
+            if oldest == "" || s.lastUsed() < p.Domains[oldest].lastUsed() {
+                oldest = d
+            }
+        }
+        delete(p.Domains, oldest)
+    }
+}
+
+func (p *HttpProxy) RecordDomain(domain string, success bool, latency time.Duration) {
+    p.MergeDomain(domain, NewDomainStat(success, latency))
+}
+
+// WorkedFor tells if the last request to domain or any of its sub domains succeeded within the given time
+func (p *HttpProxy) WorkedFor(domain string, within time.Duration) bool {
+    domain = strings.ToLower(domain)
+    since := time.Now().Add(-within).Unix()
+    for d, stat := range p.Domains {
+        if d != domain && !strings.HasSuffix(d, "."+domain) {
+            continue
+        }
+        if stat.LastSuccess >= since && stat.LastSuccess >= stat.LastFailure {
+            return true
+        }
+    }
+    return false
+}
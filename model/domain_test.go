@@ -0,0 +1,47 @@
+package model
+
+import (
+    "fmt"
+    "testing"
+)
+
+func TestDomainOf(t *testing.T) {
+    tests := map[string]string{
+        "https://WWW.Baidu.com:443/s?wd=1": "www.baidu.com",
+        "baidu.com.:80":                    "baidu.com",
+        "1.2.3.4:8080":                     "1.2.3.4",
+        "http://[::1]:80/":                 "::1",
+        "http://bad host/":                 "",
+        "evil\x00.com":                     "",
+    }
+    for target, want := range tests {
+        if got := DomainOf(target); got != want {
+            t.Errorf("DomainOf(%q) = %q, want %q", target, got, want)
+        }
+    }
+}
+
+func TestMergeDomainLimit(t *testing.T) {
+    max := config.DomainMax
+    defer func() { config.DomainMax = max }()
+    config.DomainMax = 3
+
+    p := HttpProxy{}
+    for i := 0; i < 5; i++ {
+        p.MergeDomain(fmt.Sprintf("d%d.com", i), DomainStat{Success: 1, LastSuccess: int64(100 + i)})
+    }
+    // d0 and d1 were evicted, d0 comes back and pushes out d2
+    p.MergeDomain("d0.com", DomainStat{Failure: 1, LastFailure: 50})
+    if len(p.Domains) != 3 {
+        t.Fatalf("%d domains kept, want 3", len(p.Domains))
+    }
+    for _, d := range []string{"d0.com", "d3.com", "d4.com"} {
+        if _, ok := p.Domains[d]; !ok {
+            t.Errorf("%s was evicted, kept %v", d, p.Domains)
+        }
+    }
+    p.MergeDomain("not a domain", DomainStat{Success: 1})
+    if len(p.Domains) != 3 {
+        t.Errorf("invalid domain recorded")
+    }
+}
@@ -1,9 +1,11 @@
 package model
 
 import (
+    "fmt"
     "net"
     "strconv"
     "strings"
+    "time"
 
     "github.com/phpgao/proxy_pool/ipdb"
     "github.com/phpgao/proxy_pool/util"
@@ -27,6 +29,74 @@ func filterOfCountry(v string) func(*HttpProxy) bool {
     }
 }
 
+func filterOfExitCountry(v string) func(*HttpProxy) bool {
+    return func(proxy *HttpProxy) bool {
+        return proxy.ExitCountry == strings.ToLower(v)
+    }
+}
+
+func filterOfCountryCode(v string) func(*HttpProxy) bool {
+    return func(proxy *HttpProxy) bool {
+        return strings.EqualFold(proxy.CountryCode, v)
+    }
+}
+
+func filterOfContinent(v string) func(*HttpProxy) bool {
+    return func(proxy *HttpProxy) bool {
+        return strings.EqualFold(proxy.Continent, v)
+    }
+}
+
+func filterOfRegion(v string) func(*HttpProxy) bool {
+    return func(proxy *HttpProxy) bool {
+        return strings.EqualFold(proxy.Region, v)
+    }
+}
+
+func filterOfCity(v string) func(*HttpProxy) bool {
+    return func(proxy *HttpProxy) bool {
+        return strings.EqualFold(proxy.City, v)
+    }
+}
+
+// isp names vary a lot, so match a part of it
+func filterOfIsp(v string) func(*HttpProxy) bool {
+    return func(proxy *HttpProxy) bool {
+        return proxy.Isp != "" && strings.Contains(strings.ToLower(proxy.Isp), strings.ToLower(v))
+    }
+}
+
+func filterOfStatic(v bool) func(*HttpProxy) bool {
+    return func(proxy *HttpProxy) bool {
+        return proxy.Static == v
+    }
+}
+
+func filterOfIpVersion(v int) func(*HttpProxy) bool {
+    return func(proxy *HttpProxy) bool {
+        return proxy.IpVersion() == v
+    }
+}
+
+// filterOfClass accepts a comma separated list, like residential,mobile, unknown matches the unclassified proxies
+func filterOfClass(v string) (func(*HttpProxy) bool, error) {
+    classes := make(map[string]bool)
+    for _, c := range strings.Split(strings.ToLower(v), ",") {
+        c = strings.TrimSpace(c)
+        switch c {
+        case ipdb.ClassDatacenter, ipdb.ClassMobile, ipdb.ClassResidential:
+            classes[c] = true
+        case "unknown":
+            classes[ipdb.ClassUnknown] = true
+        default:
+            return nil, fmt.Errorf("invalid class: %s", c)
+        }
+    }
+    return func(proxy *HttpProxy) bool {
+        return classes[proxy.Class]
+    }, nil
+}
+
 func filterOfScore(v int) func(*HttpProxy) bool {
     return func(proxy *HttpProxy) bool {
         return proxy.Score >= v
@@ -39,6 +109,38 @@ func filterOfLatency(v int) func(*HttpProxy) bool {
     }
 }
 
+// proxies at least as anonymous as v
+func filterOfAnonymous(v int) func(*HttpProxy) bool {
+    return func(proxy *HttpProxy) bool {
+        return proxy.Anonymous >= v
+    }
+}
+
+func filterOfProfile(v string) func(*HttpProxy) bool {
+    return func(proxy *HttpProxy) bool {
+        return proxy.PassProfile(v)
+    }
+}
+
+func filterOfDomain(v string) func(*HttpProxy) bool {
+    within := time.Duration(util.ServerConf.DomainRecent) * time.Second
+    return func(proxy *HttpProxy) bool {
+        return proxy.WorkedFor(v, within)
+    }
+}
+
+func filterOfCheckedWithin(v time.Duration) func(*HttpProxy) bool {
+    return func(proxy *HttpProxy) bool {
+        return proxy.History.CheckedWithin(v)
+    }
+}
+
+func filterOfUptime(v float64) func(*HttpProxy) bool {
+    return func(proxy *HttpProxy) bool {
+        return proxy.History.Uptime() >= v
+    }
+}
+
 func filterOfSource(v string) func(*HttpProxy) bool {
     return func(proxy *HttpProxy) bool {
         return strings.EqualFold(proxy.From, v)
@@ -85,14 +187,86 @@ func GetNewFilter(options map[string]string) (f []func(*HttpProxy) bool, err err
         if k == "country" && v != "" {
             f = append(f, filterOfCountry(v))
         }
+        if k == "country_code" && v != "" {
+            f = append(f, filterOfCountryCode(v))
+        }
+        if k == "continent" && v != "" {
+            f = append(f, filterOfContinent(v))
+        }
+        if k == "region" && v != "" {
+            f = append(f, filterOfRegion(v))
+        }
+        if k == "city" && v != "" {
+            f = append(f, filterOfCity(v))
+        }
+        if k == "isp" && v != "" {
+            f = append(f, filterOfIsp(v))
+        }
+        if k == "static" && v != "" {
+            f = append(f, filterOfStatic(v == "true"))
+        }
+        if k == "ipversion" && v != "" {
+            version, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(v), "ipv"))
+            if err != nil || (version != 4 && version != 6) {
+                return nil, fmt.Errorf("invalid ipversion: %s", v)
+            }
+            f = append(f, filterOfIpVersion(version))
+        }
+        if k == "class" && v != "" {
+            classFilter, err := filterOfClass(v)
+            if err != nil {
+                return nil, err
+            }
+            f = append(f, classFilter)
+        }
+        if k == "exit_country" && v != "" {
+            f = append(f, filterOfExitCountry(v))
+        }
+        if k == "profile" && v != "" {
+            if _, ok := util.ServerConf.GetProfile(v); !ok {
+                err = fmt.Errorf("unknown profile: %s", v)
+                return
+            }
+            f = append(f, filterOfProfile(v))
+        }
+        if k == "checked_within" && v != "" {
+            var d time.Duration
+            d, err = time.ParseDuration(v)
+            if err != nil {
+                return
+            }
+            f = append(f, filterOfCheckedWithin(d))
+        }
+        if k == "min_uptime" && v != "" {
+            var uptime float64
+            uptime, err = strconv.ParseFloat(v, 64)
+            if err != nil {
+                return
+            }
+            f = append(f, filterOfUptime(uptime))
+        }
+        if k == "domain" && v != "" {
+            f = append(f, filterOfDomain(v))
+        }
+        if k == "anonymous" && v != "" {
+            var level int
+            level, err = ParseAnonymous(v)
+            if err != nil {
+                return
+            }
+            f = append(f, filterOfAnonymous(level))
+        }
     }
     return
 }
 
 func FilterProxy(proxy *HttpProxy) bool {
-    if tmp := net.ParseIP(proxy.Ip); tmp.To4() == nil {
+    ip := net.ParseIP(strings.Trim(proxy.Ip, "[]"))
+    if ip == nil {
         return false
     }
+    // one spelling per ip, so ipv6 proxies are not stored twice
+    proxy.Ip = ip.String()
 
     port, err := strconv.Atoi(proxy.Port)
     if err != nil {
@@ -103,20 +277,76 @@ func FilterProxy(proxy *HttpProxy) bool {
         return false
     }
 
-    ipInfo, err := ipdb.Db.FindInfo(proxy.Ip, "CN")
+    geo, err := lookupGeo(proxy.Ip)
     if err != nil {
         logger.WithField("ip", proxy.Ip).WithError(err).Warn("can not find ip info")
         return false
     }
 
+    if rule := rejectedBy(geo); rule != "" {
+        logger.WithField("ip", proxy.Ip).WithField("rule", rule).Debug("rejected by geo rule")
+        return false
+    }
+    proxy.SetGeo(geo)
+
+    return true
+}
+
+type geoInfo struct {
+    Country     string
+    CountryCode string
+    Continent   string
+    Region      string
+    City        string
+    Isp         string
+    Asn         int
+    Class       string
+}
+
+// lookupGeo finds where ip is, Country is "cn" for china, or the chinese name of the country
+func lookupGeo(ip string) (geo geoInfo, err error) {
+    ipInfo, err := ipdb.Lookup(ip)
+    if err != nil {
+        return
+    }
+    geo = geoInfo{
+        Country:     ipInfo.CountryName,
+        CountryCode: strings.ToLower(ipInfo.CountryCode),
+        Continent:   strings.ToLower(ipInfo.ContinentCode),
+        Region:      ipInfo.RegionName,
+        City:        ipInfo.CityName,
+        Isp:         ipInfo.IspDomain,
+        Asn:         ipInfo.Asn,
+    }
+    geo.Class = ipdb.Classify(ip, geo.Asn)
     if ipInfo.CountryName == "中国" {
-        proxy.Country = "cn"
-    } else {
-        if util.ServerConf.OnlyChina {
-            return false
+        geo.Country = "cn"
+    }
+    return
+}
+
+// RefreshGeo looks up the ip and the exit ip again, used after the ip database changed
+func (p *HttpProxy) RefreshGeo() error {
+    geo, err := lookupGeo(p.Ip)
+    if err != nil {
+        return err
+    }
+    p.SetGeo(geo)
+    if p.ExitIp != "" {
+        if exit, err := lookupGeo(p.ExitIp); err == nil {
+            p.ExitCountry = exit.Country
         }
-        proxy.Country = ipInfo.CountryName
     }
+    return nil
+}
 
-    return true
+func (p *HttpProxy) SetGeo(geo geoInfo) {
+    p.Country = geo.Country
+    p.CountryCode = geo.CountryCode
+    p.Continent = geo.Continent
+    p.Region = geo.Region
+    p.City = geo.City
+    p.Isp = geo.Isp
+    p.Asn = geo.Asn
+    p.Class = geo.Class
 }
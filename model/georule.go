@@ -0,0 +1,89 @@
+package model
+
+import (
+    "strings"
+    "sync"
+)
+
+// names of the geo rules, also the keys of GeoRejected
+const (
+    RuleOnlyChina    = "only_china"
+    RuleAllowCountry = "allow_country"
+    RuleDenyCountry  = "deny_country"
+    RuleAllowRegion  = "allow_region"
+    RuleDenyRegion   = "deny_region"
+    RuleAllowIsp     = "allow_isp"
+    RuleDenyIsp      = "deny_isp"
+)
+
+var (
+    geoRejected     = make(map[string]int)
+    geoRejectedLock sync.Mutex
+)
+
+// GeoRejected returns how many candidates every geo rule rejected since start
+func GeoRejected() map[string]int {
+    geoRejectedLock.Lock()
+    defer geoRejectedLock.Unlock()
+    r := make(map[string]int, len(geoRejected))
+    for k, v := range geoRejected {
+        r[k] = v
+    }
+    return r
+}
+
+// rejectedBy evaluates the configured geo rules and returns the first rule the ip breaks, or ""
+func rejectedBy(geo geoInfo) (rule string) {
+    rule = checkGeo(geo)
+    if rule != "" {
+        geoRejectedLock.Lock()
+        geoRejected[rule]++
+        geoRejectedLock.Unlock()
+    }
+    return
+}
+
+func checkGeo(geo geoInfo) string {
+    country := func(v string) bool {
+        return strings.EqualFold(geo.CountryCode, v) || strings.EqualFold(geo.Country, v)
+    }
+    region := func(v string) bool {
+        return strings.EqualFold(geo.Region, v)
+    }
+    isp := func(v string) bool {
+        return geo.Isp != "" && strings.Contains(strings.ToLower(geo.Isp), strings.ToLower(v))
+    }
+
+    // OnlyChina is the shorthand of AllowCountries=cn
+    if len(config.AllowCountries) == 0 && config.OnlyChina && geo.Country != "cn" {
+        return RuleOnlyChina
+    }
+    if len(config.AllowCountries) > 0 && !matchAny(config.AllowCountries, country) {
+        return RuleAllowCountry
+    }
+    if matchAny(config.DenyCountries, country) {
+        return RuleDenyCountry
+    }
+    if len(config.AllowRegions) > 0 && !matchAny(config.AllowRegions, region) {
+        return RuleAllowRegion
+    }
+    if matchAny(config.DenyRegions, region) {
+        return RuleDenyRegion
+    }
+    if len(config.AllowIsps) > 0 && !matchAny(config.AllowIsps, isp) {
+        return RuleAllowIsp
+    }
+    if matchAny(config.DenyIsps, isp) {
+        return RuleDenyIsp
+    }
+    return ""
+}
+
+func matchAny(values []string, match func(string) bool) bool {
+    for _, v := range values {
+        if v = strings.TrimSpace(v); v != "" && match(v) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+    "math"
+    "time"
+)
+
+// History sums up every check of a proxy, the score is derived from it
+type History struct {
+    SuccessRate float64 `json:"success_rate"` // ewma of check results, 1 means always ok
+    LatencyAvg  float64 `json:"latency_avg"`  // ewma of the latency of successful checks, ms
+    Failures    int     `json:"failures"`     // consecutive failures
+    Checks      int     `json:"checks"`
+    Successes   int     `json:"successes"`
+    FirstSeen   int64   `json:"first_seen"`
+    LastChecked int64   `json:"last_checked"`
+    LastSuccess int64   `json:"last_success"`
+}
+
+// Uptime is the share of checks the proxy passed
+func (h History) Uptime() float64 {
+    if h.Checks == 0 {
+        return 0
+    }
+    return float64(h.Successes) / float64(h.Checks)
+}
+
+// CheckedWithin tells if the proxy was checked in the last d
+func (h History) CheckedWithin(d time.Duration) bool {
+    return h.LastChecked >= time.Now().Add(-d).Unix()
+}
+
+func ewma(old, value float64) float64 {
+    return config.EwmaAlpha*value + (1-config.EwmaAlpha)*old
+}
+
+// RecordCheck adds the result of a check to the history and recomputes the score
+func (p *HttpProxy) RecordCheck(success bool, latency time.Duration) {
+    h := &p.History
+    now := time.Now().Unix()
+    if h.FirstSeen == 0 {
+        h.FirstSeen = now
+    }
+
+    result := 0.0
+    if success {
+        result = 1
+    }
+    ms := float64(latency / time.Millisecond)
+    if h.Checks == 0 {
+        // the first check is all we know
+        h.SuccessRate = result
+        h.LatencyAvg = ms
+    } else {
+        h.SuccessRate = ewma(h.SuccessRate, result)
+        if success {
+            h.LatencyAvg = ewma(h.LatencyAvg, ms)
+        }
+    }
+    h.Checks++
+    h.LastChecked = now
+
+    if success {
+        h.Successes++
+        h.Failures = 0
+        h.LastSuccess = now
+        p.Latency = int(h.LatencyAvg)
+    } else {
+        h.Failures++
+    }
+    p.Score = p.History.Score()
+}
+
+// Score weighs success rate, latency and age into 0~100
+func (h History) Score() int {
+    weights := config.ScoreWeightSuccess + config.ScoreWeightLatency + config.ScoreWeightAge
+    if weights <= 0 {
+        return 0
+    }
+
+    latency := 1 - h.LatencyAvg/float64(config.GetProxyTimeout()/time.Millisecond)
+    latency = math.Max(0, math.Min(latency, 1))
+
+    var age float64
+    if h.FirstSeen > 0 && config.ScoreAgeFull > 0 {
+        age = float64(time.Now().Unix()-h.FirstSeen) / float64(config.ScoreAgeFull)
+        age = math.Max(0, math.Min(age, 1))
+    }
+
+    score := config.ScoreWeightSuccess*h.SuccessRate +
+        config.ScoreWeightLatency*latency +
+        config.ScoreWeightAge*age
+    return int(math.Round(100 * score / weights))
+}
+
+// ShouldRemove tells if the removal policy gives up on the proxy
+func (h History) ShouldRemove() bool {
+    if config.RemoveFailures > 0 && h.Failures >= config.RemoveFailures {
+        return true
+    }
+    if h.Checks >= config.RemoveMinChecks && h.SuccessRate < config.RemoveSuccessRate {
+        return true
+    }
+    return false
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+    "testing"
+    "time"
+)
+
+func TestHistory(t *testing.T) {
+    veteran := HttpProxy{}
+    veteran.RecordCheck(true, time.Second)
+    veteran.History.FirstSeen -= int64(config.ScoreAgeFull)
+    newbie := HttpProxy{}
+    newbie.RecordCheck(true, time.Second)
+    if newbie.Score >= veteran.History.Score() {
+        t.Errorf("new proxy scores %d, veteran %d", newbie.Score, veteran.History.Score())
+    }
+
+    // a single timeout must not kill a reliable proxy
+    for i := 0; i < 20; i++ {
+        veteran.RecordCheck(true, time.Second)
+    }
+    veteran.RecordCheck(false, 0)
+    if veteran.History.ShouldRemove() {
+        t.Error("one failure removed a reliable proxy")
+    }
+    if veteran.History.Failures != 1 {
+        t.Errorf("failures = %d, want 1", veteran.History.Failures)
+    }
+
+    for i := 1; i < config.RemoveFailures; i++ {
+        veteran.RecordCheck(false, 0)
+    }
+    if !veteran.History.ShouldRemove() {
+        t.Errorf("%d consecutive failures should remove the proxy", config.RemoveFailures)
+    }
+}
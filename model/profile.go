@@ -0,0 +1,97 @@
+package model
+
+import (
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/phpgao/proxy_pool/util"
+)
+
+// TestProfile requests the profile url through the proxy and checks the answer
+func (p *HttpProxy) TestProfile(profile util.Profile) (latency time.Duration, err error) {
+    timeout := config.GetProxyTimeout()
+    if profile.Timeout > 0 {
+        timeout = time.Duration(profile.Timeout) * time.Second
+    }
+    method := profile.Method
+    if method == "" {
+        method = http.MethodGet
+    }
+    status := profile.Status
+    if status == 0 {
+        status = http.StatusOK
+    }
+
+    req, err := http.NewRequest(method, profile.Url, nil)
+    if err != nil {
+        return
+    }
+    req.Header.Set("User-Agent", util.GetRandomUA())
+    client := &http.Client{
+        Transport: p.GetHttpTransport(),
+        Timeout:   timeout,
+    }
+
+    startsAt := time.Now()
+    resp, err := client.Do(req)
+    if err != nil {
+        return
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != status {
+        err = fmt.Errorf("http code %d", resp.StatusCode)
+        return
+    }
+    b, err := ioutil.ReadAll(resp.Body)
+    if err != nil {
+        return
+    }
+    latency = time.Since(startsAt)
+
+    body := string(b)
+    if profile.BodyContains != "" && !strings.Contains(body, profile.BodyContains) {
+        err = fmt.Errorf("body does not contain %q", profile.BodyContains)
+        return
+    }
+    if !profile.MatchBody(body) {
+        err = fmt.Errorf("body does not match %q", profile.BodyRegex)
+        return
+    }
+    if profile.MaxLatency > 0 && latency > time.Duration(profile.MaxLatency)*time.Millisecond {
+        err = fmt.Errorf("latency %s exceeds %dms", latency, profile.MaxLatency)
+        return
+    }
+    return
+}
+
+// TestProfiles runs every configured profile and records the results on the proxy,
+// the domain stats of this run are returned as well so they can be merged into a stored copy
+func (p *HttpProxy) TestProfiles() (domains map[string]DomainStat) {
+    if len(config.Profiles) == 0 {
+        return
+    }
+    results := make(map[string]bool, len(config.Profiles))
+    domains = make(map[string]DomainStat)
+    for _, profile := range config.Profiles {
+        latency, err := p.TestProfile(profile)
+        if err != nil {
+            logger.WithError(err).WithField("proxy", p.GetProxyWithSchema()).WithField("profile", profile.Name).Debug("test profile error")
+        }
+        results[profile.Name] = err == nil
+        domain := DomainOf(profile.Url)
+        domains[domain] = domains[domain].Merge(NewDomainStat(err == nil, latency))
+    }
+    p.Profiles = results
+    for domain, stat := range domains {
+        p.MergeDomain(domain, stat)
+    }
+    return
+}
+
+func (p *HttpProxy) PassProfile(name string) bool {
+    return p.Profiles[name]
+}
@@ -1,20 +1,20 @@
 package model
 
 import (
+    "context"
     "crypto/md5"
     "crypto/tls"
     "encoding/hex"
+    "encoding/json"
     "errors"
     "fmt"
     "io"
-    "io/ioutil"
     "net"
     "net/http"
     "net/url"
     "reflect"
     "strconv"
     "strings"
-    "time"
 
     "github.com/fatih/structs"
 
@@ -30,10 +30,19 @@ var (
 
 const (
     ConnectCommand = "%s %s %s\r\nHost: %s\r\nProxy-Connection: Keep-Alive\r\n\r\n"
-    testUrl        = "http://ip.cip.cc"
-    testHttpsUrl   = "https://ip.cip.cc"
 )
 
+const (
+    SchemaHttp    = "http"
+    SchemaHttps   = "https"
+    SchemaSocks4  = "socks4"
+    SchemaSocks4a = "socks4a"
+    SchemaSocks5  = "socks5"
+)
+
+// socks schemas in the order they are probed
+var socksSchemas = []string{SchemaSocks5, SchemaSocks4, SchemaSocks4a}
+
 type HttpProxy struct {
     Ip        string `json:"ip"`
     Port      string `json:"port"`
@@ -45,9 +54,50 @@ type HttpProxy struct {
     Anonymous int    `json:"anonymous"`
     Country   string `json:"country"`
     Deadline  string `json:"deadline"`
+    // credentials of paid proxies, encrypted in the store, see Sealed
+    Username string `json:"username,omitempty"`
+    Password string `json:"password,omitempty"`
+    // static proxies are never washed or removed, only marked unhealthy
+    Static    bool `json:"static"`
+    Unhealthy bool `json:"unhealthy"`
+    Tier      int  `json:"tier"`   // higher tiers are picked first, free proxies are 0
+    Weight    int  `json:"weight"` // share among proxies of the same tier
+    // unix time the proxy stops working, set by providers handing out short-lived proxies
+    ExpiresAt int64 `json:"expires_at,omitempty"`
+    // geolocation of Ip
+    CountryCode string `json:"country_code"`
+    Continent   string `json:"continent"`
+    Region      string `json:"region"`
+    City        string `json:"city"`
+    Isp         string `json:"isp"`
+    Asn         int    `json:"asn"`
+    Class       string `json:"class"` // datacenter, mobile, residential or empty when unknown
+    // profile name => passed
+    Profiles map[string]bool `json:"profiles,omitempty"`
+    // target domain => stat, fed by profiles and the dynamic proxy
+    Domains map[string]DomainStat `json:"domains,omitempty"`
+    History History               `json:"history"`
+    // every spider which reported the proxy
+    Sources []string `json:"sources,omitempty"`
+    // where the traffic leaves, differs from Ip for gateway style proxies
+    ExitIp      string `json:"exit_ip"`
+    ExitCountry string `json:"exit_country"`
+}
+
+// fields every stored proxy must have, the others may be missing in records written by older versions
+var requiredFields = map[string]bool{
+    "Ip":   true,
+    "Port": true,
 }
 
 func Make(m map[string]string) (newProxy HttpProxy, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            logger.WithField("fatal", r).Warn("Recovered")
+            err = fmt.Errorf("make proxy: %v", r)
+        }
+    }()
+
     rVal := reflect.ValueOf(&newProxy).Elem()
     rType := reflect.TypeOf(newProxy)
     fieldCount := rType.NumField()
@@ -56,27 +106,40 @@ func Make(m map[string]string) (newProxy HttpProxy, err error) {
         t := rType.Field(i)
         f := rVal.Field(i)
         if v, ok := m[t.Name]; ok {
-            ddd := reflect.TypeOf(v)
-            if ddd != t.Type {
-                v, _ := strconv.Atoi(v)
-                f.Set(reflect.ValueOf(v))
-            } else {
-                f.Set(reflect.ValueOf(v))
+            if err = setField(f, v); err != nil {
+                return newProxy, fmt.Errorf("%s: %w", t.Name, err)
             }
-        } else {
+        } else if requiredFields[t.Name] {
             return newProxy, errors.New(t.Name + " not found")
         }
     }
 
-    defer func() {
-        if r := recover(); r != nil {
-            logger.WithField("fatal", r).Warn("Recovered")
-        }
-    }()
-
     return
 }
 
+func setField(f reflect.Value, v string) error {
+    switch f.Kind() {
+    case reflect.String:
+        f.SetString(v)
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        i, _ := strconv.ParseInt(v, 10, 64)
+        f.SetInt(i)
+    case reflect.Bool:
+        b, _ := strconv.ParseBool(v)
+        f.SetBool(b)
+    case reflect.Float32, reflect.Float64:
+        n, _ := strconv.ParseFloat(v, 64)
+        f.SetFloat(n)
+    default:
+        // composite values are stored as json, see GetProxyMap
+        if v == "" {
+            return nil
+        }
+        return json.Unmarshal([]byte(v), f.Addr().Interface())
+    }
+    return nil
+}
+
 func (p *HttpProxy) GetKey() string {
     hash := md5.New()
     _, err := io.WriteString(hash, p.GetProxyUrl())
@@ -87,11 +150,11 @@ func (p *HttpProxy) GetKey() string {
 }
 
 func (p *HttpProxy) GetProxyUrl() string {
-    return fmt.Sprintf("%s:%s", p.Ip, p.Port)
+    return net.JoinHostPort(p.Ip, p.Port)
 }
 
 func (p *HttpProxy) GetProxyWithSchema() string {
-    return fmt.Sprintf("%s://%s:%s", p.Schema, p.Ip, p.Port)
+    return fmt.Sprintf("%s://%s", p.Schema, net.JoinHostPort(p.Ip, p.Port))
 }
 
 func (p *HttpProxy) GetFullUrl() *url.URL {
@@ -100,29 +163,98 @@ func (p *HttpProxy) GetFullUrl() *url.URL {
     if err != nil {
         panic("invalid proxy url" + _url)
     }
+    if p.HasAuth() {
+        u.User = url.UserPassword(p.Username, p.Password)
+    }
     return u
 }
 
+// GetProxyMap flattens the proxy for hash based stores, maps, slices and structs are encoded as json
 func (p *HttpProxy) GetProxyMap() map[string]interface{} {
-    return structs.Map(p)
+    m := structs.Map(p)
+    rVal := reflect.ValueOf(p).Elem()
+    rType := rVal.Type()
+    for i := 0; i < rType.NumField(); i++ {
+        switch rVal.Field(i).Kind() {
+        case reflect.Map, reflect.Slice, reflect.Struct, reflect.Ptr:
+            b, err := json.Marshal(rVal.Field(i).Interface())
+            if err != nil {
+                logger.WithError(err).WithField("field", rType.Field(i).Name).Warn("error encode field")
+                continue
+            }
+            m[rType.Field(i).Name] = string(b)
+        }
+    }
+    return m
+}
+
+func (p *HttpProxy) AddSource(source string) {
+    if source == "" {
+        return
+    }
+    for _, s := range p.Sources {
+        if s == source {
+            return
+        }
+    }
+    p.Sources = append(p.Sources, source)
+}
+
+// Renew takes what a provider hands out again for a stored proxy: the new expiry and rotated credentials
+func (p *HttpProxy) Renew(fresh HttpProxy) {
+    if fresh.ExpiresAt > 0 {
+        p.ExpiresAt = fresh.ExpiresAt
+    }
+    if fresh.HasAuth() {
+        p.Username, p.Password = fresh.Username, fresh.Password
+    }
 }
 
 func (p *HttpProxy) GetIp() string {
     return p.Ip
 }
 
+// IpVersion is 4 or 6
+func (p *HttpProxy) IpVersion() int {
+    if ip := net.ParseIP(p.Ip); ip != nil && ip.To4() == nil {
+        return 6
+    }
+    return 4
+}
+
 func (p *HttpProxy) GetPort() string {
     return p.Port
 }
 func (p *HttpProxy) IsHttps() bool {
-    return p.Schema == "https"
+    return p.Schema == SchemaHttps
+}
+
+func (p *HttpProxy) IsSocks() bool {
+    switch p.Schema {
+    case SchemaSocks4, SchemaSocks4a, SchemaSocks5:
+        return true
+    }
+    return false
+}
+
+// SetupTransport makes t send its requests through the proxy
+func (p *HttpProxy) SetupTransport(t *http.Transport) {
+    if p.IsSocks() {
+        // net/http does not speak socks4, so dial the target by ourselves
+        t.Proxy = nil
+        t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+            return p.DialContext(ctx, network, addr)
+        }
+    } else {
+        t.Proxy = http.ProxyURL(p.GetFullUrl())
+    }
 }
 
 func (p *HttpProxy) GetHttpTransport() *http.Transport {
     t := &http.Transport{
-        Proxy:           http.ProxyURL(p.GetFullUrl()),
         TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
     }
+    p.SetupTransport(t)
     return t
 }
 
@@ -149,49 +281,70 @@ func (p *HttpProxy) TestTls() error {
     return err
 }
 
-func (p *HttpProxy) testProxy(target string) (err error) {
-    timeout := 6 * time.Second
-
-    client := &http.Client{
-        Transport: p.GetHttpTransport(),
-        Timeout:   timeout,
-    }
-
-    resp, err := client.Get(target)
+// DetectSocks returns the first socks version the proxy answers to
+func (p *HttpProxy) DetectSocks() (schema string, err error) {
+    u, err := url.Parse(config.GetJudgeUrl())
     if err != nil {
         return
     }
-    defer func() {
-        err_ := resp.Body.Close()
-        if err != nil {
-            return
+    port := u.Port()
+    if port == "" {
+        port = "80"
+        if u.Scheme == "https" {
+            port = "443"
         }
-        err = err_
-        return
-    }()
+    }
+    target := net.JoinHostPort(u.Hostname(), port)
+    for _, s := range socksSchemas {
+        probe := *p
+        probe.Schema = s
+        var conn net.Conn
+        conn, err = probe.Dial("tcp", target, tcpTestTimeout)
+        if err == nil {
+            _ = conn.Close()
+            return s, nil
+        }
+    }
+    return
+}
 
-    if resp.StatusCode != 200 {
-        return fmt.Errorf("http code %d", resp.StatusCode)
+func (p *HttpProxy) testProxy(target string) (err error) {
+    client := &http.Client{
+        Transport: p.GetHttpTransport(),
+        Timeout:   config.GetProxyTimeout(),
     }
 
-    b, err := ioutil.ReadAll(resp.Body)
+    result, err := judge(client, target)
     if err != nil {
         return
     }
-    html := strings.TrimSpace(string(b))
-    if html != p.GetIp() {
+    // transparent proxies show up as "client, proxy"
+    origins := strings.Split(result.Origin, ",")
+    exitIp := strings.TrimSpace(origins[len(origins)-1])
+    if net.ParseIP(exitIp) == nil {
+        return proxyNotWork
+    }
+    if config.RequireSameExit && !net.ParseIP(exitIp).Equal(net.ParseIP(p.GetIp())) {
         return proxyNotWork
     }
+    if exitIp != p.ExitIp {
+        p.ExitIp = exitIp
+        geo, err := lookupGeo(exitIp)
+        if err != nil {
+            logger.WithField("ip", exitIp).WithError(err).Debug("can not find exit ip info")
+        }
+        p.ExitCountry = geo.Country
+    }
     return
 }
 
 func (p *HttpProxy) TestProxy() (err error) {
-    return p.testProxy(testUrl)
+    return p.testProxy(config.GetJudgeUrl())
 }
 
 // test http connect method
 func (p *HttpProxy) TestHttpTunnel() (err error) {
     // target scheme == https 时， net/http 会使用 CONNECT 方式建立隧道
     // 所以可以通过这种方式来检测 proxy 是否支持 http tunnel
-    return p.testProxy(testHttpsUrl)
+    return p.testProxy(config.GetJudgeHttpsUrl())
 }
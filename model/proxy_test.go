@@ -0,0 +1,132 @@
+package model
+
+import (
+    "fmt"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// redisString mimics how go-redis writes values of a hash
+func redisString(v interface{}) string {
+    if b, ok := v.(bool); ok {
+        if b {
+            return "1"
+        }
+        return "0"
+    }
+    return fmt.Sprint(v)
+}
+
+func TestMake(t *testing.T) {
+    tests := []struct {
+        name    string
+        proxy   HttpProxy
+        wantErr bool
+    }{
+        {
+            name: "plain fields",
+            proxy: HttpProxy{
+                Ip:        "1.2.3.4",
+                Port:      "8080",
+                Schema:    SchemaSocks5,
+                Tunnel:    true,
+                Score:     60,
+                Anonymous: AnonymousElite,
+            },
+        },
+        {
+            name: "with profiles",
+            proxy: HttpProxy{
+                Ip:       "1.2.3.4",
+                Port:     "8080",
+                Profiles: map[string]bool{"baidu": true, "google": false},
+            },
+        },
+        {
+            name: "with history",
+            proxy: HttpProxy{
+                Ip:      "1.2.3.4",
+                Port:    "8080",
+                Sources: []string{"spys", "ip89"},
+                History: History{
+                    SuccessRate: 0.75,
+                    Checks:      4,
+                    Successes:   3,
+                    FirstSeen:   1571000000,
+                    LastChecked: 1571000600,
+                },
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m := map[string]string{}
+            for k, v := range tt.proxy.GetProxyMap() {
+                m[k] = redisString(v)
+            }
+            got, err := Make(m)
+            if (err != nil) != tt.wantErr {
+                t.Errorf("Make() error = %v, wantErr %v", err, tt.wantErr)
+                return
+            }
+            if !reflect.DeepEqual(got, tt.proxy) {
+                t.Errorf("Make() got = %+v, want %+v", got, tt.proxy)
+            }
+        })
+    }
+}
+
+func TestMakeMissingField(t *testing.T) {
+    got, err := Make(map[string]string{"Ip": "1.2.3.4", "Port": "80", "Score": "70"})
+    if err != nil {
+        t.Fatalf("Make() error = %v", err)
+    }
+    if got.Score != 70 {
+        t.Errorf("Make() score = %d, want 70", got.Score)
+    }
+    if _, err = Make(map[string]string{"Ip": "1.2.3.4"}); err == nil {
+        t.Error("Make() without port should fail")
+    }
+}
+
+func TestSealed(t *testing.T) {
+    config.CredentialKey = "test"
+    proxy := HttpProxy{Ip: "1.2.3.4", Port: "80", Username: "user", Password: "pass"}
+    sealed, err := proxy.Sealed()
+    if err != nil {
+        t.Fatalf("Sealed() error = %v", err)
+    }
+    if !strings.HasPrefix(sealed.Password, sealedPrefix) || strings.Contains(sealed.Password, "pass") {
+        t.Errorf("Sealed() password = %s", sealed.Password)
+    }
+    if again, _ := sealed.Sealed(); again.Password != sealed.Password {
+        t.Error("Sealed() should not encrypt twice")
+    }
+    if err = sealed.Unseal(); err != nil {
+        t.Fatalf("Unseal() error = %v", err)
+    }
+    if !reflect.DeepEqual(sealed, proxy) {
+        t.Errorf("Unseal() got = %+v, want %+v", sealed, proxy)
+    }
+    if r := proxy.Redacted(); r.Password != redacted || r.Username != redacted {
+        t.Errorf("Redacted() got = %+v", r)
+    }
+}
+
+func TestPick(t *testing.T) {
+    free := &HttpProxy{Ip: "1.1.1.1", Port: "80"}
+    premium := &HttpProxy{Ip: "2.2.2.2", Port: "80", Static: true, Tier: 1}
+    down := &HttpProxy{Ip: "3.3.3.3", Port: "80", Static: true, Tier: 2, Unhealthy: true}
+
+    if got := Pick([]*HttpProxy{free, premium, down}); got != premium {
+        t.Errorf("Pick() got = %v, want the healthy premium proxy", got)
+    }
+    premium.Unhealthy = true
+    if got := Pick([]*HttpProxy{free, premium, down}); got != free {
+        t.Errorf("Pick() got = %v, want the free proxy", got)
+    }
+    if got := Pick([]*HttpProxy{premium, down}); got != nil {
+        t.Errorf("Pick() got = %v, want nil", got)
+    }
+}
@@ -0,0 +1,152 @@
+package model
+
+import (
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// SpiderStat sums up the runs of a spider since start
+type SpiderStat struct {
+    Name         string  `json:"name"`
+    Runs         int     `json:"runs"`
+    Skipped      int     `json:"skipped"`       // runs which fetched nothing as the pool was full, not in Runs
+    LastRun      int64   `json:"last_run"`      // unix time the last run started
+    LastDuration float64 `json:"last_duration"` // seconds
+    HttpErrors   int     `json:"http_errors"`
+    ParseErrors  int     `json:"parse_errors"`
+    Emitted      int     `json:"emitted"`   // candidates parsed from the pages
+    Filtered     int     `json:"filtered"`  // candidates which passed FilterProxy
+    Validated    int     `json:"validated"` // new proxies which passed the validator
+    // counts of the last run, a site changing its layout shows up here first
+    LastEmitted  int `json:"last_emitted"`
+    LastFiltered int `json:"last_filtered"`
+    // Targets name => validated proxies of the category
+    Categories map[string]int `json:"categories,omitempty"`
+}
+
+// ZeroYield tells if the last run found nothing
+func (s SpiderStat) ZeroYield() bool {
+    return s.Runs > 0 && s.LastEmitted == 0
+}
+
+// SpiderRun counts a single run, its methods are called from the goroutine of every start url
+type SpiderRun struct {
+    name        string
+    start       time.Time
+    fetches     int64
+    httpErrors  int64
+    parseErrors int64
+    emitted     int64
+    filtered    int64
+}
+
+var (
+    spiderStats     = make(map[string]*SpiderStat)
+    spiderStatsLock sync.Mutex
+)
+
+// getSpiderStat must be called with spiderStatsLock held
+func getSpiderStat(name string) *SpiderStat {
+    s, ok := spiderStats[name]
+    if !ok {
+        s = &SpiderStat{Name: name}
+        spiderStats[name] = s
+    }
+    return s
+}
+
+// GetSpiderStat returns a copy of the stat of a spider, empty if it never ran
+func GetSpiderStat(name string) SpiderStat {
+    spiderStatsLock.Lock()
+    defer spiderStatsLock.Unlock()
+    s := *getSpiderStat(name)
+    if s.Categories != nil {
+        s.Categories = make(map[string]int, len(s.Categories))
+        for k, v := range getSpiderStat(name).Categories {
+            s.Categories[k] = v
+        }
+    }
+    return s
+}
+
+// sourcesOf returns the spiders which brought validated proxies of the category
+func sourcesOf(category string) (sources []string) {
+    spiderStatsLock.Lock()
+    defer spiderStatsLock.Unlock()
+    for name, s := range spiderStats {
+        if s.Categories[category] > 0 {
+            sources = append(sources, name)
+        }
+    }
+    return
+}
+
+func StartRun(name string) *SpiderRun {
+    return &SpiderRun{name: name, start: time.Now()}
+}
+
+// Fetch counts a request to a start url
+func (r *SpiderRun) Fetch() {
+    atomic.AddInt64(&r.fetches, 1)
+}
+
+func (r *SpiderRun) HttpError() {
+    atomic.AddInt64(&r.httpErrors, 1)
+}
+
+func (r *SpiderRun) ParseError() {
+    atomic.AddInt64(&r.parseErrors, 1)
+}
+
+func (r *SpiderRun) Emitted(n int) {
+    atomic.AddInt64(&r.emitted, int64(n))
+}
+
+func (r *SpiderRun) Filtered() {
+    atomic.AddInt64(&r.filtered, 1)
+}
+
+// Finish adds the run to the stat of the spider, a run which fetched nothing only counts as skipped
+func (r *SpiderRun) Finish() {
+    spiderStatsLock.Lock()
+    defer spiderStatsLock.Unlock()
+    s := getSpiderStat(r.name)
+    if atomic.LoadInt64(&r.fetches) == 0 {
+        s.Skipped++
+        return
+    }
+    emitted := int(atomic.LoadInt64(&r.emitted))
+    if s.LastEmitted > 0 && emitted == 0 {
+        logger.WithField("spider", r.name).Warn("spider yield dropped to zero")
+    }
+    s.Runs++
+    s.LastRun = r.start.Unix()
+    s.LastDuration = time.Since(r.start).Seconds()
+    s.HttpErrors += int(atomic.LoadInt64(&r.httpErrors))
+    s.ParseErrors += int(atomic.LoadInt64(&r.parseErrors))
+    s.LastEmitted = emitted
+    s.LastFiltered = int(atomic.LoadInt64(&r.filtered))
+    s.Emitted += s.LastEmitted
+    s.Filtered += s.LastFiltered
+}
+
+// RecordValidated counts a new proxy the validator accepted for the spider which found it and its categories
+func RecordValidated(p *HttpProxy) {
+    var matched []string
+    for _, c := range GetCategories() {
+        if c.Match(p) {
+            matched = append(matched, c.Name)
+        }
+    }
+    spiderStatsLock.Lock()
+    defer spiderStatsLock.Unlock()
+    s := getSpiderStat(p.From)
+    s.Validated++
+    for _, name := range matched {
+        if s.Categories == nil {
+            s.Categories = make(map[string]int)
+        }
+        s.Categories[name]++
+    }
+}
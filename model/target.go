@@ -0,0 +1,188 @@
+package model
+
+import (
+    "fmt"
+    "net/url"
+    "strings"
+    "sync"
+
+    "github.com/phpgao/proxy_pool/util"
+)
+
+// Category is a PoolTarget with its filter parsed
+type Category struct {
+    util.PoolTarget
+    filters []func(*HttpProxy) bool
+}
+
+var (
+    categories     []*Category
+    categoriesOnce sync.Once
+)
+
+// NewCategory parses the filter of t, a value starting with ! matches the proxies the value does not
+func NewCategory(t util.PoolTarget) (*Category, error) {
+    values, err := url.ParseQuery(t.Filter)
+    if err != nil {
+        return nil, err
+    }
+    c := &Category{PoolTarget: t}
+    for k, vs := range values {
+        for _, v := range vs {
+            negate := strings.HasPrefix(v, "!")
+            f, err := GetNewFilter(map[string]string{k: strings.TrimPrefix(v, "!")})
+            if err != nil {
+                return nil, err
+            }
+            if len(f) == 0 {
+                return nil, fmt.Errorf("invalid filter: %s=%s", k, v)
+            }
+            for _, filter := range f {
+                if negate {
+                    filter = not(filter)
+                }
+                c.filters = append(c.filters, filter)
+            }
+        }
+    }
+    return c, nil
+}
+
+func not(f func(*HttpProxy) bool) func(*HttpProxy) bool {
+    return func(proxy *HttpProxy) bool {
+        return !f(proxy)
+    }
+}
+
+func (c *Category) Match(p *HttpProxy) bool {
+    for _, f := range c.filters {
+        if !f(p) {
+            return false
+        }
+    }
+    return true
+}
+
+// GetCategories returns the valid Targets, an invalid one is logged once and left out
+func GetCategories() []*Category {
+    categoriesOnce.Do(func() {
+        for _, t := range config.Targets {
+            c, err := NewCategory(t)
+            if err != nil {
+                logger.WithError(err).WithField("target", t.Name).Error("invalid pool target")
+                continue
+            }
+            categories = append(categories, c)
+        }
+    })
+    return categories
+}
+
+// TargetState compares a category of the pool to its target
+type TargetState struct {
+    Name    string `json:"name"`
+    Filter  string `json:"filter"`
+    Min     int    `json:"min"`
+    Current int    `json:"current"`
+    Deficit int    `json:"deficit"` // proxies missing to reach Min
+}
+
+// Composition is the pool against Targets and MaxSourceShare
+type Composition struct {
+    Total          int           `json:"total"`
+    Targets        []TargetState `json:"targets,omitempty"`
+    MaxSourceShare float64       `json:"max_source_share,omitempty"`
+    // share of the pool of every source over MaxSourceShare
+    OverShare map[string]float64 `json:"over_share,omitempty"`
+    // sources which brought proxies of a category below its target
+    helping map[string]bool
+    // a category below its target has no known source, every source may find some
+    exploring bool
+}
+
+// a source which brought that many proxies, none of them of a category without known source,
+// is not expected to find any
+const exploreLimit = 100
+
+// HasTargets tells if Targets or MaxSourceShare are set
+func HasTargets() bool {
+    return len(GetCategories()) > 0 || config.MaxSourceShare > 0
+}
+
+func GetComposition(proxies []HttpProxy) Composition {
+    c := Composition{
+        Total:          len(proxies),
+        MaxSourceShare: config.MaxSourceShare,
+        helping:        make(map[string]bool),
+    }
+    cats := GetCategories()
+    counts := make([]int, len(cats))
+    // category index => sources of its proxies
+    sources := make([]map[string]bool, len(cats))
+    bySource := make(map[string]int)
+    for i := range proxies {
+        p := &proxies[i]
+        if !p.Static {
+            bySource[p.From]++
+        }
+        for j, cat := range cats {
+            if !cat.Match(p) {
+                continue
+            }
+            counts[j]++
+            if sources[j] == nil {
+                sources[j] = make(map[string]bool)
+            }
+            sources[j][p.From] = true
+        }
+    }
+    for j, cat := range cats {
+        t := TargetState{
+            Name:    cat.Name,
+            Filter:  cat.Filter,
+            Min:     cat.Min,
+            Current: counts[j],
+        }
+        if t.Current < t.Min {
+            t.Deficit = t.Min - t.Current
+            // the pool only knows the survivors, the stats know who found the washed or expired ones
+            found := sourcesOf(cat.Name)
+            for _, source := range found {
+                c.helping[source] = true
+            }
+            for source := range sources[j] {
+                c.helping[source] = true
+            }
+            if len(found) == 0 && len(sources[j]) == 0 {
+                c.exploring = true
+            }
+        }
+        c.Targets = append(c.Targets, t)
+    }
+    if c.MaxSourceShare > 0 && c.Total > 0 {
+        for source, n := range bySource {
+            if share := float64(n) / float64(c.Total); share > c.MaxSourceShare {
+                if c.OverShare == nil {
+                    c.OverShare = make(map[string]float64)
+                }
+                c.OverShare[source] = share
+            }
+        }
+    }
+    return c
+}
+
+// Helps tells if the source brought proxies of a category below its target,
+// while such a category has no known source the sources which did not bring many proxies yet help too
+func (c Composition) Helps(source string) bool {
+    if c.helping[source] {
+        return true
+    }
+    return c.exploring && GetSpiderStat(source).Validated < exploreLimit
+}
+
+// Over tells if the source holds more than MaxSourceShare of the pool
+func (c Composition) Over(source string) bool {
+    _, ok := c.OverShare[source]
+    return ok
+}
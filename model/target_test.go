@@ -0,0 +1,57 @@
+package model
+
+import (
+    "testing"
+
+    "github.com/phpgao/proxy_pool/util"
+)
+
+func TestCategory(t *testing.T) {
+    c, err := NewCategory(util.PoolTarget{Name: "elite abroad", Filter: "anonymous=elite&country=!cn", Min: 1})
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        proxy HttpProxy
+        want  bool
+    }{
+        {HttpProxy{Anonymous: AnonymousElite, Country: "us"}, true},
+        {HttpProxy{Anonymous: AnonymousElite, Country: "cn"}, false},
+        {HttpProxy{Anonymous: AnonymousAnonymous, Country: "us"}, false},
+    }
+    for _, tt := range tests {
+        if got := c.Match(&tt.proxy); got != tt.want {
+            t.Errorf("Match(%+v) = %v, want %v", tt.proxy, got, tt.want)
+        }
+    }
+
+    if _, err = NewCategory(util.PoolTarget{Filter: "nothing=1"}); err == nil {
+        t.Error("an unknown filter should be rejected")
+    }
+}
+
+func TestCompositionHelps(t *testing.T) {
+    defer func(targets []util.PoolTarget) {
+        config.Targets = targets
+    }(config.Targets)
+    config.Targets = []util.PoolTarget{{Name: "cn", Filter: "country=cn", Min: 2}}
+    if len(GetCategories()) != 1 {
+        t.Skip("categories were loaded before the test")
+    }
+
+    pool := []HttpProxy{{Ip: "1.1.1.1", From: "abroad", Country: "us"}}
+    // nobody is known to find cn proxies, every source may
+    if c := GetComposition(pool); !c.Helps("abroad") || !c.Helps("any") {
+        t.Error("a category without known source should take any source")
+    }
+
+    // a cn proxy which was validated then washed still credits its source
+    RecordValidated(&HttpProxy{Ip: "2.2.2.2", From: "washed", Country: "cn"})
+    pool = append(pool, HttpProxy{Ip: "3.3.3.3", From: "pooled", Country: "cn"})
+    c := GetComposition(pool)
+    for source, want := range map[string]bool{"washed": true, "pooled": true, "abroad": false} {
+        if got := c.Helps(source); got != want {
+            t.Errorf("Helps(%s) = %v, want %v", source, got, want)
+        }
+    }
+}
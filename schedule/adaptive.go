@@ -0,0 +1,104 @@
+package schedule
+
+import (
+	"github.com/phpgao/proxy_pool/model"
+	"github.com/phpgao/proxy_pool/validator"
+	"github.com/robfig/cron/v3"
+	"sync"
+	"time"
+)
+
+// most doublings of the interval of a spider which keeps yielding nothing
+const maxBackoff = 6
+
+// adaptive is the schedule of a spider in AdaptiveCron mode, the cron of the spider is the base interval:
+// halved when the spider brought AdaptiveYield new proxies since its previous run, and again when it helps
+// filling the pool (see validator.Wanted), doubled for every run in a row which brought no new proxy
+type adaptive struct {
+	name string
+	base cron.Schedule
+
+	lock      sync.Mutex
+	runs      int // runs of the spider the interval was computed from
+	validated int
+	failures  int
+	fruitful  bool
+	interval  time.Duration
+}
+
+func newAdaptive(name string, base cron.Schedule) *adaptive {
+	stat := model.GetSpiderStat(name)
+	return &adaptive{
+		name:      name,
+		base:      base,
+		runs:      stat.Runs,
+		validated: stat.Validated,
+	}
+}
+
+// Next is called by the cron when the spider starts, so the runs which ended before are taken into account
+func (a *adaptive) Next(t time.Time) time.Time {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	base := a.base.Next(t).Sub(t).Round(time.Second)
+
+	// a run skipped as the pool was full is not in Runs, so it neither backs off nor resets the backoff,
+	// a run emitting nothing failed to fetch or parse, or found a page without proxies,
+	// one whose proxies all failed the validation found only dead ones
+	stat := model.GetSpiderStat(a.name)
+	if stat.Runs != a.runs {
+		a.runs = stat.Runs
+		validated := stat.Validated - a.validated
+		a.fruitful = validated >= config.AdaptiveYield
+		a.validated = stat.Validated
+		if stat.LastEmitted == 0 || validated == 0 {
+			a.failures++
+		} else {
+			a.failures = 0
+		}
+	}
+
+	interval := base
+	if a.failures > 0 {
+		backoff := a.failures
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		interval = base << uint(backoff)
+	} else {
+		if a.fruitful {
+			interval /= 2
+		}
+		// a spider yielding nothing does not help filling the pool
+		if validator.Wanted(a.name) {
+			interval /= 2
+		}
+	}
+
+	// the bounds do not override the base interval
+	min, max := time.Duration(config.AdaptiveMin)*time.Second, time.Duration(config.AdaptiveMax)*time.Second
+	if min > base {
+		min = base
+	}
+	if max < base {
+		max = base
+	}
+	if interval < min {
+		interval = min
+	}
+	if interval > max {
+		interval = max
+	}
+	if interval != a.interval && a.interval != 0 {
+		logger.WithField("spider", a.name).Infof("interval changed: %s -> %s", a.interval, interval)
+	}
+	a.interval = interval
+	return t.Add(interval)
+}
+
+// Interval is the last interval Next used
+func (a *adaptive) Interval() time.Duration {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.interval
+}
@@ -0,0 +1,89 @@
+package schedule
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/phpgao/proxy_pool/model"
+	"github.com/phpgao/proxy_pool/validator"
+	"github.com/robfig/cron/v3"
+)
+
+// spiderRun is a finished run of a spider as the stats see it
+type spiderRun struct {
+	fetched   bool
+	emitted   int
+	validated int
+}
+
+func (r spiderRun) record(name string) {
+	run := model.StartRun(name)
+	if r.fetched {
+		run.Fetch()
+	}
+	run.Emitted(r.emitted)
+	run.Finish()
+	for i := 0; i < r.validated; i++ {
+		model.RecordValidated(&model.HttpProxy{From: name})
+	}
+}
+
+func TestAdaptiveNext(t *testing.T) {
+	defer func(min, max, yield, target int) {
+		config.AdaptiveMin, config.AdaptiveMax, config.AdaptiveYield, config.PoolTarget = min, max, yield, target
+	}(config.AdaptiveMin, config.AdaptiveMax, config.AdaptiveYield, config.PoolTarget)
+	config.AdaptiveMin, config.AdaptiveMax, config.AdaptiveYield = 60, 3600, 10
+
+	var (
+		ok      = spiderRun{fetched: true, emitted: 5, validated: 3}
+		dead    = spiderRun{fetched: true, emitted: 5}
+		fruit   = spiderRun{fetched: true, emitted: 20, validated: 10}
+		zero    = spiderRun{fetched: true}
+		skipped = spiderRun{}
+	)
+	tests := []struct {
+		name   string
+		base   time.Duration
+		runs   []spiderRun
+		wanted bool
+		want   time.Duration
+	}{
+		{"no run yet", 10 * time.Minute, nil, false, 10 * time.Minute},
+		{"plain run", 10 * time.Minute, []spiderRun{ok}, false, 10 * time.Minute},
+		{"fruitful", 10 * time.Minute, []spiderRun{fruit}, false, 5 * time.Minute},
+		{"wanted", 10 * time.Minute, []spiderRun{ok}, true, 5 * time.Minute},
+		{"fruitful and wanted", 10 * time.Minute, []spiderRun{fruit}, true, 150 * time.Second},
+		{"one failure", 10 * time.Minute, []spiderRun{zero}, false, 20 * time.Minute},
+		{"nothing validated", 10 * time.Minute, []spiderRun{dead}, true, 20 * time.Minute},
+		{"nothing validated twice", 10 * time.Minute, []spiderRun{zero, dead}, false, 40 * time.Minute},
+		{"two failures", 10 * time.Minute, []spiderRun{zero, zero}, true, 40 * time.Minute},
+		{"backoff over max", 10 * time.Minute, []spiderRun{zero, zero, zero}, false, time.Hour},
+		{"success resets backoff", 10 * time.Minute, []spiderRun{zero, zero, ok}, false, 10 * time.Minute},
+		{"skipped is no failure", 10 * time.Minute, []spiderRun{skipped, skipped}, false, 10 * time.Minute},
+		{"skipped keeps backoff", 10 * time.Minute, []spiderRun{zero, skipped}, false, 20 * time.Minute},
+		{"halved below min", 2 * time.Minute, []spiderRun{fruit}, true, time.Minute},
+		{"base below min", 30 * time.Second, []spiderRun{fruit}, true, 30 * time.Second},
+		{"base over max", 2 * time.Hour, []spiderRun{zero, zero}, false, 2 * time.Hour},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := fmt.Sprintf("adaptive_test_%d", i)
+			config.PoolTarget = 0
+			if tt.wanted {
+				config.PoolTarget = validator.CurrentProxyCount + 1
+			}
+			a := newAdaptive(name, cron.Every(tt.base))
+			// whole seconds, cron.Every drops the fraction of the start time
+			now := time.Now().Truncate(time.Second)
+			a.Next(now)
+			for _, r := range tt.runs {
+				r.record(name)
+				now = a.Next(now)
+			}
+			if got := a.Next(now).Sub(now); got != tt.want {
+				t.Errorf("Next() in %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
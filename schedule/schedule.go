@@ -1,39 +1,53 @@
 package schedule
 
 import (
+	"fmt"
 	"github.com/phpgao/proxy_pool/db"
 	"github.com/phpgao/proxy_pool/job"
 	"github.com/phpgao/proxy_pool/queue"
 	"github.com/phpgao/proxy_pool/util"
 	"github.com/phpgao/proxy_pool/validator"
 	"github.com/robfig/cron/v3"
+	"sort"
+	"sync"
 	"time"
 )
 
+const internalTask = "internal"
+
 type Scheduler struct {
 	spiders []job.Crawler
 	cronMap map[string]cron.EntryID
 	cron    *cron.Cron
+	// tasks wrap the spiders and the internal check, by name
+	tasks map[string]*task
+	// lock guards cronMap, which the api changes while the cron runs
+	lock sync.Mutex
 }
 
 var (
-	config = util.ServerConf
-	logger = util.GetLogger("schedule")
+	config  = util.ServerConf
+	logger  = util.GetLogger("schedule")
+	current *Scheduler
 )
 
+// GetScheduler returns the running scheduler, nil when not running as Manager
+func GetScheduler() *Scheduler {
+	return current
+}
+
 func (s *Scheduler) Run() {
 	logger.Info("adding scheduler...")
+	s.lock.Lock()
 	for _, _spider := range s.spiders {
 		// trigger once
-		go _spider.Run()
-
-		cronID, err := s.cron.AddJob(_spider.Cron(), _spider)
+		go s.tasks[_spider.Name()].Run()
 
-		if err != nil {
+		if err := s.schedule(_spider); err != nil {
 			logger.WithError(err).Errorf("error add cron with spider %s", _spider.Name())
 		}
-		s.cronMap[_spider.Name()] = cronID
 	}
+	s.lock.Unlock()
 	_, _ = s.cron.AddFunc("@every 1m", func() {
 		s.report("")
 	})
@@ -41,7 +55,172 @@ func (s *Scheduler) Run() {
 	s.cron.Start()
 }
 
+// schedule (re)adds the cron entry of a spider with its current settings, a disabled one gets none,
+// it must be called with lock held
+func (s *Scheduler) schedule(c job.Crawler) error {
+	if id, ok := s.cronMap[c.Name()]; ok {
+		s.cron.Remove(id)
+		delete(s.cronMap, c.Name())
+	}
+	if !job.IsEnabled(c) {
+		return nil
+	}
+	schedule, err := cron.ParseStandard(job.CronOf(c))
+	if err != nil {
+		return err
+	}
+	if config.AdaptiveCron {
+		schedule = newAdaptive(c.Name(), schedule)
+	}
+	s.cronMap[c.Name()] = s.cron.Schedule(schedule, s.tasks[c.Name()])
+	return nil
+}
+
+func (s *Scheduler) getSpider(name string) job.Crawler {
+	for _, c := range s.spiders {
+		if c.Name() == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Override changes the settings of a spider at once, see job.SetOption
+func (s *Scheduler) Override(o util.SpiderOption) (util.SpiderOption, error) {
+	c := s.getSpider(o.Name)
+	if c == nil {
+		return o, fmt.Errorf("unknown spider: %q", o.Name)
+	}
+	if o.Cron != "" {
+		if _, err := cron.ParseStandard(o.Cron); err != nil {
+			return o, err
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	o = job.SetOption(o)
+	logger.WithField("spider", o.Name).Info("spider settings overridden")
+	return o, s.schedule(c)
+}
+
+// ResetOverride gives a spider its own settings back
+func (s *Scheduler) ResetOverride(name string) error {
+	c := s.getSpider(name)
+	if c == nil {
+		return fmt.Errorf("unknown spider: %q", name)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	job.DeleteOption(name)
+	logger.WithField("spider", name).Info("spider settings reset")
+	return s.schedule(c)
+}
+
+// Reschedule adds the cron entry of a spider again after its own cron changed,
+// an invalid cron keeps the old entry
+func (s *Scheduler) Reschedule(name string) error {
+	c := s.getSpider(name)
+	if c == nil {
+		return fmt.Errorf("unknown spider: %q", name)
+	}
+	if _, err := cron.ParseStandard(job.CronOf(c)); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	logger.WithField("spider", name).Info("spider rescheduled")
+	return s.schedule(c)
+}
+
+// Tasks lists the spiders and the internal check with their cron entries
+func (s *Scheduler) Tasks() []TaskState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var states []TaskState
+	for name, t := range s.tasks {
+		state := t.state()
+		if name == internalTask {
+			state.Cron = config.GetInternalCron()
+		} else {
+			state.Cron = job.CronOf(s.getSpider(name))
+		}
+		if id, ok := s.cronMap[name]; ok {
+			entry := s.cron.Entry(id)
+			state.Scheduled = entry.Valid()
+			state.Prev = entry.Prev
+			state.Next = entry.Next
+			if a, ok := entry.Schedule.(*adaptive); ok {
+				state.Interval = a.Interval().String()
+			}
+		}
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+	return states
+}
+
+func (s *Scheduler) getTask(name string) (*task, error) {
+	t, ok := s.tasks[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown task: %q", name)
+	}
+	return t, nil
+}
+
+// Trigger runs a task now, paused or not, it fails if the task is running already
+func (s *Scheduler) Trigger(name string) error {
+	t, err := s.getTask(name)
+	if err != nil {
+		return err
+	}
+	if c := s.getSpider(name); c != nil && !job.IsEnabled(c) {
+		return fmt.Errorf("spider %s is not enabled", name)
+	}
+	if t.isRunning() {
+		return alreadyRunning
+	}
+	logger.WithField("task", name).Info("task triggered")
+	go func() {
+		if err := t.run(); err != nil {
+			logger.WithField("task", name).Info("task is running already")
+		}
+	}()
+	return nil
+}
+
+// Pause stops the cron from running a task, or every task when name is empty,
+// a run in progress goes on
+func (s *Scheduler) Pause(name string) error {
+	return s.setPaused(name, true)
+}
+
+// Resume undoes Pause
+func (s *Scheduler) Resume(name string) error {
+	return s.setPaused(name, false)
+}
+
+func (s *Scheduler) setPaused(name string, paused bool) error {
+	if name == "" {
+		for _, t := range s.tasks {
+			t.setPaused(paused)
+		}
+		logger.Infof("all tasks paused: %v", paused)
+		return nil
+	}
+	t, err := s.getTask(name)
+	if err != nil {
+		return err
+	}
+	t.setPaused(paused)
+	logger.WithField("task", name).Infof("task paused: %v", paused)
+	return nil
+}
+
 func (s *Scheduler) report(spiderKey string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	if spiderKey != "" {
 		if entryId, ok := s.cronMap[spiderKey]; ok {
 			if ok := s.cron.Entry(entryId).Next.IsZero(); ok {
@@ -69,22 +248,29 @@ func NewScheduler() *Scheduler {
 	s := &Scheduler{
 		cron:    cron.New(),
 		cronMap: make(map[string]cron.EntryID),
+		tasks:   make(map[string]*task),
 	}
 
 	s.spiders = job.GetSpiders(queue.GetNewChan())
+	for _, c := range s.spiders {
+		s.tasks[c.Name()] = newTask(c.Name(), c)
+	}
 
 	internalJob := Internal{
 		channel: queue.GetOldChan(),
 		db:      db.GetDb(),
 	}
+	internal := newTask(internalTask, &internalJob)
+	s.tasks[internalTask] = internal
 
-	go internalJob.Run()
+	go internal.Run()
 
-	id, err := s.cron.AddJob(config.GetInternalCron(), &internalJob)
+	id, err := s.cron.AddJob(config.GetInternalCron(), internal)
 
 	if err != nil {
 		logger.WithError(err).Error("error initial internal job")
 	}
-	s.cronMap["internal"] = id
+	s.cronMap[internalTask] = id
+	current = s
 	return s
 }
@@ -0,0 +1,96 @@
+package schedule
+
+import (
+	"errors"
+	"github.com/phpgao/proxy_pool/validator"
+	"github.com/robfig/cron/v3"
+	"sync/atomic"
+	"time"
+)
+
+var alreadyRunning = errors.New("already running")
+
+// task is what the cron runs for a spider or the internal check,
+// a tick is skipped while the task is paused or its previous run is not over
+type task struct {
+	name    string
+	job     cron.Job
+	running int32
+	paused  int32
+	// unix time the last run started and ended
+	lastStart int64
+	lastEnd   int64
+}
+
+// TaskState is a task as the api shows it
+type TaskState struct {
+	Name      string    `json:"name"`
+	Cron      string    `json:"cron"`
+	Scheduled bool      `json:"scheduled"` // false for a disabled spider, it has no cron entry
+	Paused    bool      `json:"paused"`
+	Running   bool      `json:"running"`
+	Prev      time.Time `json:"prev"`
+	Next      time.Time `json:"next"`
+	Interval  string    `json:"interval,omitempty"` // the current interval in AdaptiveCron mode
+	LastStart int64     `json:"last_start"`
+	LastEnd   int64     `json:"last_end"`
+}
+
+func newTask(name string, j cron.Job) *task {
+	return &task{name: name, job: j}
+}
+
+// Run is called by the cron
+func (t *task) Run() {
+	if t.isPaused() {
+		logger.WithField("task", t.name).Debug("task is paused, skip")
+		return
+	}
+	if validator.OverShare(t.name) {
+		logger.WithField("task", t.name).Debug("source is over MaxSourceShare, skip")
+		return
+	}
+	if err := t.run(); err != nil {
+		logger.WithField("task", t.name).Info("previous run is not over, skip")
+	}
+}
+
+// run runs the job unless it is running already
+func (t *task) run() error {
+	if !atomic.CompareAndSwapInt32(&t.running, 0, 1) {
+		return alreadyRunning
+	}
+	defer atomic.StoreInt32(&t.running, 0)
+	atomic.StoreInt64(&t.lastStart, time.Now().Unix())
+	defer func() {
+		atomic.StoreInt64(&t.lastEnd, time.Now().Unix())
+	}()
+	t.job.Run()
+	return nil
+}
+
+func (t *task) isRunning() bool {
+	return atomic.LoadInt32(&t.running) == 1
+}
+
+func (t *task) isPaused() bool {
+	return atomic.LoadInt32(&t.paused) == 1
+}
+
+func (t *task) setPaused(paused bool) {
+	var v int32
+	if paused {
+		v = 1
+	}
+	atomic.StoreInt32(&t.paused, v)
+}
+
+func (t *task) state() TaskState {
+	return TaskState{
+		Name:      t.name,
+		Paused:    t.isPaused(),
+		Running:   t.isRunning(),
+		LastStart: atomic.LoadInt64(&t.lastStart),
+		LastEnd:   atomic.LoadInt64(&t.lastEnd),
+	}
+}
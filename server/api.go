@@ -1,13 +1,15 @@
 package server
 
 import (
-    "math/rand"
+    "crypto/subtle"
     "net/http"
     "strconv"
+    "strings"
 
     "github.com/gin-gonic/gin"
 
     "github.com/phpgao/proxy_pool/db"
+    "github.com/phpgao/proxy_pool/ipdb"
     "github.com/phpgao/proxy_pool/job"
     "github.com/phpgao/proxy_pool/model"
     "github.com/phpgao/proxy_pool/util"
@@ -21,16 +23,18 @@ var (
 const home = "https://github.com/phpgao/proxy_pool"
 
 type Resp struct {
-    Code   int         `json:"code"`
-    Error  string      `json:"error"`
-    Total  int         `json:"total"`
-    Score  interface{} `json:"score,omitempty"`
-    Tunnel int         `json:"tunnel"`
-    Cn     int         `json:"cn,omitempty"`
-    Data   interface{} `json:"data"`
-    Get    string      `json:"get,omitempty"`
-    Random string      `json:"random,omitempty"`
-    Home   string      `json:"home,omitempty"`
+    Code     int         `json:"code"`
+    Error    string      `json:"error"`
+    Total    int         `json:"total"`
+    Score    interface{} `json:"score,omitempty"`
+    Tunnel   int         `json:"tunnel"`
+    Cn       int         `json:"cn,omitempty"`
+    Rejected interface{} `json:"rejected,omitempty"`
+    Targets  interface{} `json:"targets,omitempty"`
+    Data     interface{} `json:"data"`
+    Get      string      `json:"get,omitempty"`
+    Random   string      `json:"random,omitempty"`
+    Home     string      `json:"home,omitempty"`
 }
 
 func routerApi() http.Handler {
@@ -42,6 +46,17 @@ func routerApi() http.Handler {
     e.GET("/get", handlerQuery)
     e.GET("/random", handlerRandom)
     e.GET("/random_text", handlerRandomText)
+    e.GET("/judge", handlerJudge)
+    e.GET("/spiders", handlerSpiders)
+    e.PUT("/spiders/:name", handlerSpiderOverride)
+    e.DELETE("/spiders/:name", handlerSpiderReset)
+    e.GET("/scheduler", handlerTasks)
+    e.POST("/scheduler/run", handlerTaskRun)
+    e.POST("/scheduler/pause", handlerTaskPause)
+    e.POST("/scheduler/resume", handlerTaskResume)
+    e.POST("/geo/refresh", handlerGeoRefresh)
+    e.POST("/static", handlerStaticAdd)
+    e.DELETE("/static", handlerStaticRemove)
 
     return e
 }
@@ -88,7 +103,7 @@ func handlerStatus(c *gin.Context) {
             if p.Country == "cn" {
                 cn++
             }
-            status[p.From] ++
+            status[p.From]++
             setDefault(scores, strconv.Itoa(p.Score), 0, 1)
         }
     }
@@ -96,12 +111,43 @@ func handlerStatus(c *gin.Context) {
     resp.Score = scores
     resp.Tunnel = tunnels
     resp.Cn = cn
+    resp.Rejected = model.GeoRejected()
+    if model.HasTargets() {
+        resp.Targets = model.GetComposition(proxies)
+    }
     resp.Home = home
     resp.Get = "/get?schema=&score="
     resp.Random = "/random?schema=&score="
     c.JSON(http.StatusOK, resp)
 }
 
+// handlerGeoRefresh updates the geo data of every stored proxy from the current ip database
+func handlerGeoRefresh(c *gin.Context) {
+    resp := Resp{
+        Code: http.StatusOK,
+    }
+    if !requireToken(c, &resp) {
+        return
+    }
+    proxies := storeEngine.GetAll()
+    for _, p := range proxies {
+        var lookupErr error
+        err := storeEngine.Update(p, func(proxy *model.HttpProxy) {
+            lookupErr = proxy.RefreshGeo()
+        })
+        if err == nil {
+            err = lookupErr
+        }
+        if err != nil {
+            logger.WithError(err).WithField("proxy", p.GetProxyWithSchema()).Debug("refresh geo error")
+            continue
+        }
+        resp.Total++
+    }
+    resp.Data = ipdb.GetGeo().Name()
+    c.JSON(http.StatusOK, resp)
+}
+
 func handlerRandom(c *gin.Context) {
     resp := Resp{
         Code: http.StatusOK,
@@ -113,12 +159,13 @@ func handlerRandom(c *gin.Context) {
         return
     }
 
-    if len(proxies) == 0 {
+    p := pick(proxies)
+    if p == nil {
         resp.Error = "no proxy"
         c.JSON(http.StatusOK, resp)
         return
     }
-    resp.Data = proxies[rand.Intn(len(proxies))]
+    resp.Data = p
     resp.Total = len(proxies)
 
     c.JSON(http.StatusOK, resp)
@@ -131,31 +178,104 @@ func handlerRandomText(c *gin.Context) {
         return
     }
 
-    if len(proxies) == 0 {
+    p := pick(proxies)
+    if p == nil {
         c.String(http.StatusOK, "")
         return
     }
+    if p.HasAuth() && authorized(c) {
+        c.String(http.StatusOK, p.GetFullUrl().String())
+        return
+    }
+    c.String(http.StatusOK, p.GetProxyWithSchema())
+}
 
-    c.String(http.StatusOK, proxies[rand.Intn(len(proxies))].GetProxyWithSchema())
+// pick prefers the healthy proxies of the highest tier
+func pick(proxies []model.HttpProxy) *model.HttpProxy {
+    candidates := make([]*model.HttpProxy, len(proxies))
+    for i := range proxies {
+        candidates[i] = &proxies[i]
+    }
+    return model.Pick(candidates)
 }
 
 func Filter(c *gin.Context) (proxies []model.HttpProxy, err error) {
+    schema := c.Query("schema")
     tunnel := c.Query("tunnel")
     // score above given number
     score := c.Query("score")
     latency := c.Query("latency")
     _source := c.Query("source")
     country := c.Query("country")
+    exitCountry := c.Query("exit_country")
+    countryCode := c.Query("country_code")
+    continent := c.Query("continent")
+    region := c.Query("region")
+    city := c.Query("city")
+    isp := c.Query("isp")
+    class := c.Query("class")
+    ipVersion := c.Query("ipversion")
+    static := c.Query("static")
+    anonymous := c.Query("anonymous")
+    profile := c.Query("profile")
+    domain := c.Query("domain")
+    checkedWithin := c.Query("checked_within")
+    minUptime := c.Query("min_uptime")
     limit := c.DefaultQuery("limit", "0")
 
-    return storeEngine.Get(map[string]string{
-        "tunnel":  tunnel,
-        "score":   score,
-        "source":  _source,
-        "country": country,
-        "limit":   limit,
-        "latency": latency,
+    proxies, err = storeEngine.Get(map[string]string{
+        "schema":         schema,
+        "tunnel":         tunnel,
+        "score":          score,
+        "source":         _source,
+        "country":        country,
+        "exit_country":   exitCountry,
+        "country_code":   countryCode,
+        "continent":      continent,
+        "region":         region,
+        "city":           city,
+        "isp":            isp,
+        "class":          class,
+        "ipversion":      ipVersion,
+        "static":         static,
+        "limit":          limit,
+        "latency":        latency,
+        "anonymous":      anonymous,
+        "profile":        profile,
+        "domain":         domain,
+        "checked_within": checkedWithin,
+        "min_uptime":     minUptime,
     })
+    if !authorized(c) {
+        for i := range proxies {
+            proxies[i] = proxies[i].Redacted()
+        }
+    }
+    return
+}
+
+// authorized tells if the caller presents ApiToken, no one is when it is not set
+func authorized(c *gin.Context) bool {
+    token := util.ServerConf.ApiToken
+    if token == "" {
+        return false
+    }
+    given := c.Query("token")
+    if h := c.GetHeader("Authorization"); strings.HasPrefix(h, "Bearer ") {
+        given = strings.TrimPrefix(h, "Bearer ")
+    }
+    return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// requireToken answers 401 when the caller is not authorized
+func requireToken(c *gin.Context, resp *Resp) bool {
+    if authorized(c) {
+        return true
+    }
+    resp.Code = http.StatusUnauthorized
+    resp.Error = "ApiToken is required"
+    c.JSON(resp.Code, resp)
+    return false
 }
 
 func setDefault(h map[string]int, k string, v, inc int) (set bool, r int) {
@@ -0,0 +1,82 @@
+package server
+
+import (
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/phpgao/proxy_pool/model"
+    "github.com/phpgao/proxy_pool/util"
+)
+
+var domainStats = &domainRecorder{
+    pending: make(map[string]*pendingDomains),
+}
+
+type pendingDomains struct {
+    proxy   model.HttpProxy
+    domains map[string]model.DomainStat
+}
+
+// domainRecorder collects how upstreams did in the dynamic proxy,
+// and writes them to the store in batches instead of once per request
+type domainRecorder struct {
+    lock    sync.Mutex
+    pending map[string]*pendingDomains
+}
+
+func (d *domainRecorder) Record(proxy *model.HttpProxy, target string, success bool, latency time.Duration) {
+    domain := model.DomainOf(target)
+    if domain == "" {
+        return
+    }
+    d.lock.Lock()
+    defer d.lock.Unlock()
+    key := proxy.GetKey()
+    p, ok := d.pending[key]
+    if !ok {
+        p = &pendingDomains{
+            proxy:   *proxy,
+            domains: make(map[string]model.DomainStat),
+        }
+        d.pending[key] = p
+    }
+    // MergeDomain would drop the extra domains anyway
+    if _, ok := p.domains[domain]; !ok && util.ServerConf.DomainMax > 0 && len(p.domains) >= util.ServerConf.DomainMax {
+        return
+    }
+    p.domains[domain] = p.domains[domain].Merge(model.NewDomainStat(success, latency))
+}
+
+func (d *domainRecorder) Flush() {
+    d.lock.Lock()
+    pending := d.pending
+    d.pending = make(map[string]*pendingDomains)
+    d.lock.Unlock()
+
+    for _, p := range pending {
+        err := storeEngine.Update(p.proxy, func(stored *model.HttpProxy) {
+            for domain, stat := range p.domains {
+                stored.MergeDomain(domain, stat)
+            }
+        })
+        if err != nil {
+            logger.WithError(err).WithField("proxy", p.proxy.GetProxyWithSchema()).Debug("error save domain stats")
+        }
+    }
+}
+
+func (d *domainRecorder) Run(interval time.Duration) {
+    for range time.Tick(interval) {
+        d.Flush()
+    }
+}
+
+// a blocked upstream usually still gets an answer, just not a useful one
+func responseSucceeded(resp *http.Response) bool {
+    switch resp.StatusCode {
+    case http.StatusForbidden, http.StatusProxyAuthRequired, http.StatusTooManyRequests:
+        return false
+    }
+    return resp.StatusCode < http.StatusInternalServerError
+}
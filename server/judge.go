@@ -0,0 +1,78 @@
+package server
+
+import (
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/tls"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "math/big"
+    "net"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "github.com/phpgao/proxy_pool/model"
+    "github.com/phpgao/proxy_pool/util"
+)
+
+// handlerJudge echoes the address and headers of the caller,
+// validators send requests here through a proxy to see what the proxy leaks
+func handlerJudge(c *gin.Context) {
+    // never trust X-Forwarded-For here, finding it is the whole point
+    origin, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+    if err != nil {
+        origin = c.Request.RemoteAddr
+    }
+
+    headers := make(map[string]string, len(c.Request.Header))
+    for k, v := range c.Request.Header {
+        headers[k] = strings.Join(v, ", ")
+    }
+
+    protocol := "http"
+    if c.Request.TLS != nil {
+        protocol = "https"
+    }
+
+    c.JSON(http.StatusOK, model.JudgeResult{
+        Origin:   origin,
+        Headers:  headers,
+        Protocol: protocol,
+    })
+}
+
+func routerJudge() http.Handler {
+    if !util.ServerConf.Debug {
+        gin.SetMode(gin.ReleaseMode)
+    }
+    e := gin.New()
+    e.Use(gin.Recovery())
+    e.GET("/judge", handlerJudge)
+
+    return e
+}
+
+// selfSignedCert is the certificate of the https judge when JudgeCertFile is not set
+func selfSignedCert() (tls.Certificate, error) {
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil {
+        return tls.Certificate{}, err
+    }
+    template := x509.Certificate{
+        SerialNumber: big.NewInt(time.Now().UnixNano()),
+        Subject:      pkix.Name{CommonName: "proxy_pool judge"},
+        NotBefore:    time.Now().Add(-time.Hour),
+        NotAfter:     time.Now().AddDate(10, 0, 0),
+        KeyUsage:     x509.KeyUsageDigitalSignature,
+        ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+    }
+    der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+    if err != nil {
+        return tls.Certificate{}, err
+    }
+    return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
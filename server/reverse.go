@@ -2,16 +2,17 @@ package server
 
 import (
     "crypto/tls"
-    "fmt"
+    "errors"
     "io"
-    "math/rand"
     "net"
     "net/http"
+    "net/url"
     "time"
 
     "github.com/apex/log"
 
     "github.com/phpgao/proxy_pool/cache"
+    "github.com/phpgao/proxy_pool/db"
     "github.com/phpgao/proxy_pool/model"
     "github.com/phpgao/proxy_pool/util"
 )
@@ -20,46 +21,68 @@ var (
     timeout = time.Duration(util.ServerConf.HttpsConnectTimeOut) * time.Second
 )
 
+var noProxyAvailable = errors.New("no proxy available")
+
+const (
+    connectEstablished = "HTTP/1.1 200 Connection established\r\n\r\n"
+    filterHeader       = "Proxy-Pool-Filter"
+)
+
+// pickProxy chooses a random proxy of the given kind which matches the filters
+// sent in the Proxy-Pool-Filter header, e.g. "anonymous=elite&country=cn"
+func pickProxy(r *http.Request, kind string) (*model.HttpProxy, error) {
+    p := *cache.Cache.Get()
+    proxies := p[kind]
+
+    options := map[string]string{
+        "anonymous": util.ServerConf.ProxyAnonymous,
+        "class":     util.ServerConf.ProxyClass,
+    }
+    if h := r.Header.Get(filterHeader); h != "" {
+        values, err := url.ParseQuery(h)
+        if err != nil {
+            return nil, err
+        }
+        for k := range values {
+            options[k] = values.Get(k)
+        }
+    }
+    r.Header.Del(filterHeader)
+
+    filters, err := model.GetNewFilter(options)
+    if err != nil {
+        return nil, err
+    }
+    var matched []*model.HttpProxy
+    for i := range proxies {
+        if db.Match(filters, &proxies[i]) {
+            matched = append(matched, &proxies[i])
+        }
+    }
+    // the highest tier with a healthy proxy wins
+    if p := model.Pick(matched); p != nil {
+        return p, nil
+    }
+    return nil, noProxyAvailable
+}
+
 func handleTunneling(w http.ResponseWriter, r *http.Request) {
     var err error
-    p := *cache.Cache.Get()
-    proxies := p["tunnel"]
     var destConn net.Conn
 
-    if proxies == nil {
-        http.Error(w, "no proxy available", http.StatusServiceUnavailable)
+    proxy, err := pickProxy(r, "tunnel")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusServiceUnavailable)
         return
     } else {
-        l := len(proxies)
-        proxy := proxies[rand.Intn(l)]
         logger.WithField("proxy", proxy.GetProxyWithSchema()).Info("dynamic https")
-        msg := fmt.Sprintf(model.ConnectCommand, http.MethodConnect, r.Host, "HTTP/1.1", r.Host)
-
-        if proxy.IsHttps() {
-            conf := &tls.Config{InsecureSkipVerify: true}
-            dialer := &net.Dialer{
-                Timeout: timeout,
-            }
-            destConn, err = tls.DialWithDialer(dialer, "tcp", proxy.GetProxyUrl(), conf)
-        } else {
-            destConn, err = net.DialTimeout("tcp", proxy.GetProxyUrl(), timeout)
-        }
-        if err != nil {
-            http.Error(w, err.Error(), http.StatusServiceUnavailable)
-            return
-        }
-        _, err = destConn.Write([]byte(msg))
 
+        // CONNECT for http(s) upstreams, handshake for socks upstreams
+        startsAt := time.Now()
+        destConn, err = proxy.Dial("tcp", r.Host, timeout)
+        domainStats.Record(proxy, r.Host, err == nil, time.Since(startsAt))
         if err != nil {
             http.Error(w, err.Error(), http.StatusServiceUnavailable)
-            _ = destConn.Close()
-            return
-        }
-
-        err = destConn.SetReadDeadline(time.Now().Add(timeout))
-        if err != nil {
-            http.Error(w, err.Error(), http.StatusServiceUnavailable)
-            _ = destConn.Close()
             return
         }
     }
@@ -67,11 +90,19 @@ func handleTunneling(w http.ResponseWriter, r *http.Request) {
     hijacker, ok := w.(http.Hijacker)
     if !ok {
         http.Error(w, "Hijacking not supported", http.StatusInternalServerError)
+        _ = destConn.Close()
         return
     }
     clientConn, _, err := hijacker.Hijack()
     if err != nil {
         http.Error(w, err.Error(), http.StatusServiceUnavailable)
+        _ = destConn.Close()
+        return
+    }
+    _, err = clientConn.Write([]byte(connectEstablished))
+    if err != nil {
+        _ = clientConn.Close()
+        _ = destConn.Close()
         return
     }
     go transfer(destConn, clientConn)
@@ -93,16 +124,13 @@ func handleHTTP(w http.ResponseWriter, req *http.Request) {
     var err error
     var Transport http.RoundTripper
 
-    p := *cache.Cache.Get()
-    proxies := p["forward"]
-    if proxies == nil {
-        http.Error(w, "no proxy available", http.StatusServiceUnavailable)
+    proxy, err := pickProxy(req, "forward")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusServiceUnavailable)
         return
     } else {
-        proxy := proxies[rand.Intn(len(proxies))]
         logger.WithField("proxy", proxy.GetProxyWithSchema()).Debug("http forward proxy")
-        Transport = &http.Transport{
-            Proxy: http.ProxyURL(proxy.GetFullUrl()),
+        transport := &http.Transport{
             DialContext: (&net.Dialer{
                 Timeout:   timeout,
                 KeepAlive: timeout,
@@ -115,13 +143,18 @@ func handleHTTP(w http.ResponseWriter, req *http.Request) {
             // skip cert check
             TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
         }
+        proxy.SetupTransport(transport)
+        Transport = transport
     }
 
+    startsAt := time.Now()
     resp, err := Transport.RoundTrip(req)
     if err != nil {
+        domainStats.Record(proxy, req.Host, false, 0)
         http.Error(w, err.Error(), http.StatusServiceUnavailable)
         return
     }
+    domainStats.Record(proxy, req.Host, responseSucceeded(resp), time.Since(startsAt))
     defer resp.Body.Close()
     copyHeader(w.Header(), resp.Header)
     w.WriteHeader(resp.StatusCode)
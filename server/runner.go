@@ -29,13 +29,23 @@ func RunService() {
         }
     }()
 
-    var ApiService, ProxyService *http.Server
+    var ApiService, ProxyService, JudgeService *http.Server
 
-    if util.ServerConf.EnableApi {
+    // judge only mode serves nothing but the judge, so it can live on another box
+    judgeOnly := util.ServerConf.JudgeOnly
+
+    // the validators use the /judge of this node when JudgeHost is set and JudgeUrl is not
+    builtinJudge := util.ServerConf.Worker && util.ServerConf.JudgeHost != "" && util.ServerConf.JudgeUrl == ""
+
+    if util.ServerConf.EnableApi || judgeOnly || builtinJudge {
         addr := fmt.Sprintf("%s:%d", util.ServerConf.ApiBind, util.ServerConf.ApiPort)
+        handler := routerJudge()
+        if util.ServerConf.EnableApi && !judgeOnly {
+            handler = routerApi()
+        }
         ApiService = &http.Server{
             Addr:         addr,
-            Handler:      routerApi(),
+            Handler:      handler,
             ReadTimeout:  5 * time.Second,
             WriteTimeout: 10 * time.Second,
         }
@@ -47,7 +57,35 @@ func RunService() {
 
     }
 
-    if util.ServerConf.EnableProxy {
+    hasCert := util.ServerConf.JudgeCertFile != "" && util.ServerConf.JudgeKeyFile != ""
+    builtinHttpsJudge := util.ServerConf.Worker && util.ServerConf.JudgeHost != "" && util.ServerConf.JudgeHttpsUrl == ""
+    if hasCert || judgeOnly || builtinHttpsJudge {
+        addr := fmt.Sprintf("%s:%d", util.ServerConf.ApiBind, util.ServerConf.JudgeTlsPort)
+        JudgeService = &http.Server{
+            Addr:         addr,
+            Handler:      routerJudge(),
+            ReadTimeout:  5 * time.Second,
+            WriteTimeout: 10 * time.Second,
+        }
+        JudgeService.SetKeepAlivesEnabled(false)
+        // the validators do not verify the judge, a self-signed certificate is enough
+        if !hasCert {
+            cert, err := selfSignedCert()
+            if err != nil {
+                logger.WithError(err).Fatal("can not create the judge certificate")
+            }
+            JudgeService.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+        }
+
+        g.Go(func() error {
+            logger.WithField("addr", addr).Info("JudgeService listen and serve tls")
+            return JudgeService.ListenAndServeTLS(util.ServerConf.JudgeCertFile, util.ServerConf.JudgeKeyFile)
+        })
+    }
+
+    if util.ServerConf.EnableProxy && !judgeOnly {
+        go domainStats.Run(time.Duration(util.ServerConf.DomainFlush) * time.Second)
+
         addr := fmt.Sprintf("%s:%d", util.ServerConf.ProxyBind, util.ServerConf.ProxyPort)
         ProxyService = &http.Server{
             Addr:         addr,
@@ -76,11 +114,18 @@ func RunService() {
                 logger.WithError(err).Error("Could not gracefully shutdown api server")
             }
         }
+        if JudgeService != nil {
+            logger.Info("shutting down judge server")
+            if err = JudgeService.Shutdown(context.Background()); err != nil {
+                logger.WithError(err).Error("Could not gracefully shutdown judge server")
+            }
+        }
         if ProxyService != nil {
             logger.Info("shutting down proxy server")
             if err = ProxyService.Shutdown(context.Background()); err != nil {
                 logger.WithError(err).Error("Could not gracefully shutdown proxy server")
             }
+            domainStats.Flush()
         }
         close(IdleConnClosed)
     }()
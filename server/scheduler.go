@@ -0,0 +1,60 @@
+package server
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+
+    "github.com/phpgao/proxy_pool/schedule"
+)
+
+// handlerTasks lists the spiders and the internal check with their previous and next run
+func handlerTasks(c *gin.Context) {
+    resp := Resp{
+        Code: http.StatusOK,
+    }
+    scheduler := schedule.GetScheduler()
+    if scheduler == nil {
+        resp.Code = http.StatusServiceUnavailable
+        resp.Error = "the scheduler runs on the Manager only"
+        c.JSON(resp.Code, resp)
+        return
+    }
+    tasks := scheduler.Tasks()
+    resp.Data = tasks
+    resp.Total = len(tasks)
+    c.JSON(http.StatusOK, resp)
+}
+
+// handlerTaskRun runs the task of the name query now, internal is the check of the stored proxies
+func handlerTaskRun(c *gin.Context) {
+    taskAction(c, (*schedule.Scheduler).Trigger)
+}
+
+// handlerTaskPause pauses the task of the name query, or every task without it
+func handlerTaskPause(c *gin.Context) {
+    taskAction(c, (*schedule.Scheduler).Pause)
+}
+
+// handlerTaskResume resumes the task of the name query, or every task without it
+func handlerTaskResume(c *gin.Context) {
+    taskAction(c, (*schedule.Scheduler).Resume)
+}
+
+func taskAction(c *gin.Context, action func(*schedule.Scheduler, string) error) {
+    resp := Resp{
+        Code: http.StatusOK,
+    }
+    scheduler, ok := getScheduler(c, &resp)
+    if !ok {
+        return
+    }
+    if err := action(scheduler, c.Query("name")); err != nil {
+        resp.Code = http.StatusBadRequest
+        resp.Error = err.Error()
+        c.JSON(resp.Code, resp)
+        return
+    }
+    resp.Data = scheduler.Tasks()
+    c.JSON(http.StatusOK, resp)
+}
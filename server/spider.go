@@ -0,0 +1,151 @@
+package server
+
+import (
+    "net/http"
+    "sort"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "github.com/phpgao/proxy_pool/job"
+    "github.com/phpgao/proxy_pool/model"
+    "github.com/phpgao/proxy_pool/schedule"
+    "github.com/phpgao/proxy_pool/util"
+)
+
+type spiderReport struct {
+    model.SpiderStat
+    Cron      string `json:"cron"`
+    Enabled   bool   `json:"enabled"`
+    ZeroYield bool   `json:"zero_yield"`
+    Alive     int    `json:"alive"`
+    Alive1h   int    `json:"alive_1h"`  // proxies of the spider still in the pool an hour after they were found
+    Alive24h  int    `json:"alive_24h"` // and a day after
+    // Override is what the Spiders config and the api changed
+    Override *util.SpiderOption `json:"override,omitempty"`
+}
+
+// handlerSpiders reports every spider, zero_yield=true only returns the ones whose last run found nothing
+func handlerSpiders(c *gin.Context) {
+    resp := Resp{
+        Code: http.StatusOK,
+    }
+    onlyZero := c.Query("zero_yield") == "true"
+
+    reports := make(map[string]*spiderReport)
+    for _, s := range job.ListOfSpider {
+        stat := model.GetSpiderStat(s.Name())
+        r := &spiderReport{
+            SpiderStat: stat,
+            Cron:       job.CronOf(s),
+            Enabled:    job.IsEnabled(s),
+            ZeroYield:  stat.ZeroYield(),
+        }
+        if o, ok := job.GetOption(s.Name()); ok {
+            r.Override = &o
+        }
+        reports[s.Name()] = r
+    }
+
+    now := time.Now().Unix()
+    for _, p := range storeEngine.GetAll() {
+        sources := p.Sources
+        if len(sources) == 0 {
+            sources = []string{p.From}
+        }
+        age := now - p.History.FirstSeen
+        for _, source := range sources {
+            r, ok := reports[source]
+            if !ok {
+                continue
+            }
+            r.Alive++
+            if p.History.FirstSeen == 0 {
+                continue
+            }
+            if age >= int64(time.Hour/time.Second) {
+                r.Alive1h++
+            }
+            if age >= int64(24*time.Hour/time.Second) {
+                r.Alive24h++
+            }
+        }
+    }
+
+    var data []*spiderReport
+    for _, r := range reports {
+        if onlyZero && !r.ZeroYield {
+            continue
+        }
+        data = append(data, r)
+    }
+    sort.Slice(data, func(i, j int) bool {
+        return data[i].Name < data[j].Name
+    })
+    resp.Data = data
+    resp.Total = len(data)
+    c.JSON(http.StatusOK, resp)
+}
+
+// handlerSpiderOverride changes the settings of a spider without a restart, the body is a util.SpiderOption in json
+// and only its non-zero fields are changed
+func handlerSpiderOverride(c *gin.Context) {
+    resp := Resp{
+        Code: http.StatusOK,
+    }
+    scheduler, ok := getScheduler(c, &resp)
+    if !ok {
+        return
+    }
+    var o util.SpiderOption
+    if err := c.ShouldBindJSON(&o); err != nil {
+        resp.Code = http.StatusBadRequest
+        resp.Error = err.Error()
+        c.JSON(resp.Code, resp)
+        return
+    }
+    o.Name = c.Param("name")
+    o, err := scheduler.Override(o)
+    if err != nil {
+        resp.Code = http.StatusBadRequest
+        resp.Error = err.Error()
+        c.JSON(resp.Code, resp)
+        return
+    }
+    resp.Total = 1
+    resp.Data = o
+    c.JSON(http.StatusOK, resp)
+}
+
+// handlerSpiderReset drops the overrides of a spider, the config ones included
+func handlerSpiderReset(c *gin.Context) {
+    resp := Resp{
+        Code: http.StatusOK,
+    }
+    scheduler, ok := getScheduler(c, &resp)
+    if !ok {
+        return
+    }
+    if err := scheduler.ResetOverride(c.Param("name")); err != nil {
+        resp.Code = http.StatusBadRequest
+        resp.Error = err.Error()
+        c.JSON(resp.Code, resp)
+        return
+    }
+    resp.Total = 1
+    c.JSON(http.StatusOK, resp)
+}
+
+func getScheduler(c *gin.Context, resp *Resp) (*schedule.Scheduler, bool) {
+    if !requireToken(c, resp) {
+        return nil, false
+    }
+    scheduler := schedule.GetScheduler()
+    if scheduler == nil {
+        resp.Code = http.StatusServiceUnavailable
+        resp.Error = "the scheduler runs on the Manager only"
+        c.JSON(resp.Code, resp)
+        return nil, false
+    }
+    return scheduler, true
+}
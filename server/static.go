@@ -0,0 +1,75 @@
+package server
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+
+    "github.com/phpgao/proxy_pool/model"
+    "github.com/phpgao/proxy_pool/util"
+    "github.com/phpgao/proxy_pool/validator"
+)
+
+// handlerStaticAdd pins a proxy, the body is a util.StaticProxy in json
+func handlerStaticAdd(c *gin.Context) {
+    resp := Resp{
+        Code: http.StatusOK,
+    }
+    p, ok := bindStatic(c, &resp)
+    if !ok {
+        return
+    }
+    if err := validator.AddStatic(p); err != nil {
+        resp.Code = http.StatusInternalServerError
+        resp.Error = err.Error()
+        c.JSON(resp.Code, resp)
+        return
+    }
+    resp.Total = 1
+    resp.Data = p.Redacted()
+    c.JSON(http.StatusOK, resp)
+}
+
+// handlerStaticRemove removes a static proxy, only the url of the body is used, a proxy not pinned is refused
+func handlerStaticRemove(c *gin.Context) {
+    resp := Resp{
+        Code: http.StatusOK,
+    }
+    p, ok := bindStatic(c, &resp)
+    if !ok {
+        return
+    }
+    if err := validator.RemoveStatic(p); err != nil {
+        switch err {
+        case validator.ErrNoStatic:
+            resp.Code = http.StatusNotFound
+        case validator.ErrNotStatic:
+            resp.Code = http.StatusBadRequest
+        default:
+            resp.Code = http.StatusInternalServerError
+        }
+        resp.Error = err.Error()
+        c.JSON(resp.Code, resp)
+        return
+    }
+    resp.Total = 1
+    c.JSON(http.StatusOK, resp)
+}
+
+func bindStatic(c *gin.Context, resp *Resp) (p model.HttpProxy, ok bool) {
+    if !requireToken(c, resp) {
+        return
+    }
+    var s util.StaticProxy
+    err := c.ShouldBindJSON(&s)
+    if err == nil {
+        p, err = model.NewStaticProxy(s)
+    }
+    if err != nil {
+        resp.Code = http.StatusBadRequest
+        resp.Error = err.Error()
+        c.JSON(resp.Code, resp)
+        return
+    }
+    return p, true
+}
@@ -12,8 +12,9 @@ import (
 )
 
 const (
-	RegIp                = `(?:(?:[0,1]?\d?\d|2[0-4]\d|25[0-5])\.){3}(?:[0,1]?\d?\d|2[0-4]\d|25[0-5])`
-	RegProxy             = `(?:(?:[0,1]?\d?\d|2[0-4]\d|25[0-5])\.){3}(?:[0,1]?\d?\d|2[0-4]\d|25[0-5]):\d{0,5}`
+	RegIp                = `(?:(?:25[0-5]|2[0-4]\d|[0,1]?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|[0,1]?\d?\d)` // longest octets first, 1.2.3.240 is not cut to 1.2.3.24
+	RegIp6               = `[0-9a-fA-F]{0,4}(?::[0-9a-fA-F]{0,4}){2,7}`
+	RegProxy             = `(?:` + RegIp + `|\[` + RegIp6 + `\]):\d{0,5}` // ipv6 proxies are written as [ip]:port
 	RegProxyWithoutColon = `(?:(?:[0,1]?\d?\d|2[0-4]\d|25[0-5])\.){3}(?:[0,1]?\d?\d|2[0-4]\d|25[0-5]) \d{0,5}`
 )
 
@@ -31,6 +32,15 @@ func FindIp(s string) string {
 	return rs[0]
 }
 
+// SplitProxy splits what RegProxy matched into ip and port
+func SplitProxy(s string) (ip, port string, ok bool) {
+	ip, port, err := net.SplitHostPort(strings.TrimSpace(s))
+	if err != nil || net.ParseIP(ip) == nil {
+		return "", "", false
+	}
+	return ip, port, true
+}
+
 func GetWsFromChrome(url string) (ws string, err error) {
 	host, port := Parse(url)
 	var chromeApi string
@@ -10,43 +10,58 @@ import (
 
 var logger = util.GetLogger("max")
 
-type Proxies []model.HttpProxy
-
-func (p Proxies) Len() int {
-	return len(p)
-}
-
-func (p Proxies) Less(i, j int) bool {
-	return p[i].Score < p[j].Score
-}
-
-func (p Proxies) Swap(i, j int) {
-	p[i], p[j] = p[j], p[i]
-}
-
 var (
 	CurrentProxyCount int
-	lock              sync.RWMutex
+	// the pool against Targets, only computed when they are set
+	composition model.Composition
+	lock        sync.RWMutex
 )
 
 func Update() {
 	logger.Debug("begin wash db")
 	lock.Lock()
 	defer lock.Unlock()
-	CurrentProxyCount = db.GetDb().Len()
+	measure()
 	if CurrentProxyCount > util.ServerConf.MaxProxy {
 		logger.Debug("need wash db")
 		Wash()
+		measure()
 	}
 	logger.Debug("wash done")
 }
 
+// measure counts the pool, and compares it to Targets when they are set, lock must be held
+func measure() {
+	if model.HasTargets() {
+		all := db.GetDb().GetAll()
+		CurrentProxyCount = len(all)
+		composition = model.GetComposition(all)
+	} else {
+		CurrentProxyCount = db.GetDb().Len()
+	}
+}
+
 func CanDo() bool {
 	lock.RLock()
 	defer lock.RUnlock()
 	return CurrentProxyCount < util.ServerConf.MaxProxy
 }
 
+// Wanted tells if crawling the source helps filling the pool, as the pool has fewer proxies than PoolTarget
+// or the source found proxies of a category below its target
+func Wanted(source string) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return CurrentProxyCount < util.ServerConf.PoolTarget || composition.Helps(source)
+}
+
+// OverShare tells if the source holds more than MaxSourceShare of the pool
+func OverShare(source string) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return composition.Over(source)
+}
+
 // 删除低分代理，直到剩余指定个数
 func Wash() {
 	max := util.ServerConf.MaxProxy
@@ -59,14 +74,77 @@ func Wash() {
 		return
 	}
 
-	tmp := Proxies(all)
-	sort.Sort(tmp)
-	err := db.GetDb().RemoveAll(tmp[:l-max])
+	n := l - max
+	victims := pickVictims(all, model.GetCategories(), n, max)
+	err := db.GetDb().RemoveAll(victims)
 
 	if err != nil {
 		logger.WithError(err).Error("error wash db")
 		return
 	}
-	logger.WithField("count", l-max).Debug("db washed")
+	logger.WithField("count", len(victims)).Debug("db washed")
+	return
+}
+
+// pickVictims chooses n proxies to wash, the lowest scores first, from the sources over MaxSourceShare
+// of a pool of size, then from the ones no category at or below its target needs, then from any,
+// static proxies are never washed
+func pickVictims(all []model.HttpProxy, cats []*model.Category, n, size int) (victims []model.HttpProxy) {
+	counts := make([]int, len(cats))
+	bySource := make(map[string]int)
+	// index of all => indexes of its categories
+	matches := make([][]int, len(all))
+	var candidates []int
+	for i := range all {
+		p := &all[i]
+		for j, c := range cats {
+			if c.Match(p) {
+				counts[j]++
+				matches[i] = append(matches[i], j)
+			}
+		}
+		if !p.Static {
+			bySource[p.From]++
+			candidates = append(candidates, i)
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return all[candidates[a]].Score < all[candidates[b]].Score
+	})
+
+	limit := int(util.ServerConf.MaxSourceShare * float64(size))
+	over := func(i int) bool {
+		return limit > 0 && bySource[all[i].From] > limit
+	}
+	needed := func(i int) bool {
+		for _, j := range matches[i] {
+			if counts[j] <= cats[j].Min {
+				return true
+			}
+		}
+		return false
+	}
+	passes := []func(int) bool{
+		func(i int) bool { return over(i) && !needed(i) },
+		func(i int) bool { return !needed(i) },
+		func(int) bool { return true },
+	}
+	removed := make([]bool, len(all))
+	for _, pass := range passes {
+		for _, i := range candidates {
+			if len(victims) >= n {
+				return
+			}
+			if removed[i] || !pass(i) {
+				continue
+			}
+			removed[i] = true
+			victims = append(victims, all[i])
+			bySource[all[i].From]--
+			for _, j := range matches[i] {
+				counts[j]--
+			}
+		}
+	}
 	return
 }
@@ -0,0 +1,61 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/phpgao/proxy_pool/model"
+	"github.com/phpgao/proxy_pool/util"
+)
+
+func TestPickVictims(t *testing.T) {
+	defer func(share float64) {
+		util.ServerConf.MaxSourceShare = share
+	}(util.ServerConf.MaxSourceShare)
+	util.ServerConf.MaxSourceShare = 0.5
+
+	cn, err := model.NewCategory(util.PoolTarget{Name: "cn", Filter: "country=cn", Min: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := func(ip, from, country string, score int, static bool) model.HttpProxy {
+		return model.HttpProxy{Ip: ip, Port: "80", From: from, Country: country, Score: score, Static: static}
+	}
+	all := []model.HttpProxy{
+		proxy("1.0.0.1", "big", "us", 50, false),
+		proxy("1.0.0.2", "big", "us", 60, false),
+		proxy("1.0.0.3", "big", "us", 70, false),
+		proxy("1.0.0.4", "big", "cn", 10, false),
+		proxy("1.0.0.5", "small", "cn", 20, false),
+		proxy("1.0.0.6", "small", "us", 40, false),
+		proxy("1.0.0.7", "static", "us", 0, true),
+	}
+	tests := []struct {
+		name string
+		n    int
+		want []string
+	}{
+		// big holds 4 of a pool of 6, over its share of 3, its cn proxy is needed
+		{"over share first", 1, []string{"1.0.0.1"}},
+		// once big is at its share the lowest not needed proxy goes
+		{"then not needed", 2, []string{"1.0.0.1", "1.0.0.6"}},
+		{"then any but static", 6, []string{"1.0.0.1", "1.0.0.6", "1.0.0.2", "1.0.0.3", "1.0.0.4", "1.0.0.5"}},
+		{"static never", 7, []string{"1.0.0.1", "1.0.0.6", "1.0.0.2", "1.0.0.3", "1.0.0.4", "1.0.0.5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			victims := pickVictims(all, []*model.Category{cn}, tt.n, 6)
+			var got []string
+			for _, v := range victims {
+				got = append(got, v.Ip)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("pickVictims() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("pickVictims() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -38,8 +38,16 @@ func NewValidator() {
 
                     if storeEngine.Exists(*p) {
                         logger.WithField("proxy", proxy.GetProxyUrl()).Infof("proxy existed, ignore it")
+                        err = storeEngine.Update(*p, func(stored *model.HttpProxy) {
+                            stored.AddSource(p.From)
+                            stored.Renew(*p)
+                        })
+                        if err != nil {
+                            logger.WithError(err).WithField("proxy", p.GetProxyUrl()).Debug("add source error")
+                        }
                         return
                     }
+                    p.AddSource(p.From)
 
                     err = p.TestTcp()
                     if err != nil {
@@ -49,26 +57,46 @@ func NewValidator() {
                     err = p.TestTls()
                     if err != nil {
                         logger.WithError(err).WithField("proxy", p.GetProxyUrl()).Debug("test tls error")
-                        p.Schema = "http"
+                        p.Schema = model.SchemaHttp
                     } else {
-                        p.Schema = "https"
+                        p.Schema = model.SchemaHttps
                     }
                     startsAt := time.Now()
                     err = p.TestProxy()
                     if err != nil {
                         logger.WithError(err).WithField("proxy", p.GetProxyUrl()).Debug("test http proxy error")
+                        // socks servers do not answer http, probe them only when http failed
+                        schema, socksErr := p.DetectSocks()
+                        if socksErr != nil {
+                            logger.WithError(socksErr).WithField("proxy", p.GetProxyUrl()).Debug("test socks error")
+                            return
+                        }
+                        p.Schema = schema
+                        startsAt = time.Now()
+                        err = p.TestProxy()
+                    }
+                    if err != nil {
+                        logger.WithError(err).WithField("proxy", p.GetProxyWithSchema()).Debug("test proxy error")
                         return
                     } else {
-                        p.Latency = int(time.Since(startsAt) / time.Millisecond)
+                        p.RecordCheck(true, time.Since(startsAt))
+                        if anonymous, err := p.TestAnonymous(); err != nil {
+                            logger.WithError(err).WithField("proxy", p.GetProxyUrl()).Debug("test anonymous error")
+                        } else {
+                            p.Anonymous = anonymous
+                        }
                         err = p.TestHttpTunnel()
                         if err != nil {
                             logger.WithError(err).WithField("proxy", p.GetProxyUrl()).Debug("test http tunnel proxy error")
                         } else {
                             p.Tunnel = true
                         }
+                        p.TestProfiles()
                     }
                     logger.WithField("proxy", p.GetProxyUrl()).Info("added new proxy")
-                    storeEngine.Add(*p)
+                    if storeEngine.Add(*p) {
+                        model.RecordValidated(p)
+                    }
                 }(proxy)
             }
         }()
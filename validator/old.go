@@ -2,6 +2,7 @@ package validator
 
 import (
     "sync"
+    "time"
 
     "github.com/apex/log"
 
@@ -35,27 +36,59 @@ func OldValidator() {
                         return
                     }
 
-                    var score int
+                    var latency time.Duration
+                    var domains map[string]model.DomainStat
                     err := p.TestTcp()
                     if err != nil {
                         logger.WithError(err).WithField("proxy", p.GetProxyWithSchema()).Debug("test tcp error")
-                        score = -100
                     } else {
-                        score = 10
-                        err := p.TestProxy()
+                        startsAt := time.Now()
+                        err = p.TestProxy()
+                        latency = time.Since(startsAt)
                         if err != nil {
                             logger.WithError(err).WithField("proxy", p.GetProxyWithSchema()).Debug("test http tunnel error")
-                            score = -100
+                        } else {
+                            domains = p.TestProfiles()
                         }
                     }
-                    logger.WithFields(log.Fields{
-                        "score": score,
-                        "proxy": p.GetProxyWithSchema(),
-                    }).Info("set score")
 
-                    err = storeEngine.AddScore(p, score)
+                    success := err == nil
+                    var remove bool
+                    err = storeEngine.Update(p, func(stored *model.HttpProxy) {
+                        stored.RecordCheck(success, latency)
+                        if success {
+                            stored.ExitIp = p.ExitIp
+                            stored.ExitCountry = p.ExitCountry
+                        }
+                        if domains != nil {
+                            stored.Profiles = p.Profiles
+                        }
+                        for domain, stat := range domains {
+                            stored.MergeDomain(domain, stat)
+                        }
+                        if stored.Static {
+                            stored.Unhealthy = !success
+                        }
+                        remove = !stored.Static && stored.History.ShouldRemove()
+                        p = *stored
+                    })
                     if err != nil {
                         logger.WithError(err).WithField("proxy", p.GetProxyWithSchema()).Error("set score error")
+                        return
+                    }
+
+                    logger.WithFields(log.Fields{
+                        "score":    p.Score,
+                        "failures": p.History.Failures,
+                        "proxy":    p.GetProxyWithSchema(),
+                    }).Info("set score")
+
+                    if remove {
+                        logger.WithField("proxy", p.GetProxyWithSchema()).Info("remove proxy by policy")
+                        err = storeEngine.Remove(p)
+                        if err != nil {
+                            logger.WithError(err).WithField("proxy", p.GetProxyWithSchema()).Error("remove proxy error")
+                        }
                     }
 
                 }(*proxy)
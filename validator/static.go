@@ -0,0 +1,62 @@
+package validator
+
+import (
+    "errors"
+
+    "github.com/phpgao/proxy_pool/model"
+)
+
+var (
+    ErrNoStatic  = errors.New("static proxy not found")
+    ErrNotStatic = errors.New("not a static proxy")
+)
+
+// AddStatic stores a pinned proxy, or pins a stored one with the given tier, weight and credentials
+func AddStatic(p model.HttpProxy) error {
+    if storeEngine.Exists(p) {
+        return storeEngine.Update(p, func(stored *model.HttpProxy) {
+            stored.Schema = p.Schema
+            stored.Username = p.Username
+            stored.Password = p.Password
+            stored.Static = true
+            stored.Tier = p.Tier
+            stored.Weight = p.Weight
+            stored.AddSource(model.SourceStatic)
+        })
+    }
+    if err := p.RefreshGeo(); err != nil {
+        logger.WithError(err).WithField("proxy", p.GetProxyWithSchema()).Warn("can not find ip info")
+    }
+    if !storeEngine.Add(p) {
+        return errors.New("add static proxy error")
+    }
+    return nil
+}
+
+// RemoveStatic removes a pinned proxy, the proxies found by the spiders are left to the validators
+func RemoveStatic(p model.HttpProxy) error {
+    for _, stored := range storeEngine.GetAll() {
+        if stored.GetKey() != p.GetKey() {
+            continue
+        }
+        if !stored.Static {
+            return ErrNotStatic
+        }
+        return storeEngine.Remove(stored)
+    }
+    return ErrNoStatic
+}
+
+// LoadStatic stores the static proxies of the config
+func LoadStatic() {
+    for _, s := range config.StaticProxies {
+        p, err := model.NewStaticProxy(s)
+        if err != nil {
+            logger.WithError(err).Error("invalid static proxy")
+            continue
+        }
+        if err = AddStatic(p); err != nil {
+            logger.WithError(err).WithField("proxy", p.GetProxyWithSchema()).Error("add static proxy error")
+        }
+    }
+}